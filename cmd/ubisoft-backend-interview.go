@@ -1,18 +1,38 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"os/signal"
 	"runtime"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
 
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/auth"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/cache"
+	appconfig "github.com/kwiesmueller/ubisoft-backend-interview/pkg/config"
 	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/database"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/health"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/ingest"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/memory"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/metrics"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/ratelimit"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/server"
 
 	"github.com/golang/glog"
 	"github.com/kolide/kit/version"
+	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/playnet-public/libs/log"
+	"github.com/uber/jaeger-client-go/config"
+	jaegerzap "github.com/uber/jaeger-client-go/log/zap"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -20,23 +40,96 @@ import (
 const (
 	appName = "ubisoft-backend-interview"
 	appKey  = "ubisoft-backend-interview"
+	// envPrefix of environment variables holding settings
+	envPrefix = "FEEDBACK_"
 )
 
+// settings can be given as flags, environment variables or in the config file
+var settings = appconfig.New(flag.CommandLine, envPrefix)
+
 var (
-	maxprocs    = flag.Int("maxprocs", runtime.NumCPU(), "max go procs")
-	dbg         = flag.Bool("debug", false, "enable debug mode")
 	versionInfo = flag.Bool("version", true, "show version info")
-	sentryDsn   = flag.String("sentryDsn", "", "sentry dsn key")
+	configFile  = flag.String("config", os.Getenv(envPrefix+"CONFIG_FILE"), "path to a YAML config file")
+
+	maxprocs  = settings.Int("maxprocs", runtime.NumCPU(), "max go procs")
+	dbg       = settings.Bool("debug", false, "enable debug mode")
+	sentryDsn = settings.Secret("sentryDsn", "", "sentry dsn key")
+
+	listenAddr     = settings.String("listenAddr", ":8080", "address to serve http requests on")
+	store          = settings.String("store", "postgres", "feedback storage backend (postgres|memory)")
+	requestTimeout = settings.Duration("requestTimeout", 5*time.Second, "maximum duration of a single request, 0 to disable")
+
+	authMode       = settings.String("auth", "jwt", "player authentication (jwt|hmac|header), header trusts Ubi-UserId unverified and is only meant for migrating clients")
+	authJwksFile   = settings.String("authJwksFile", "", "JSON Web Key Set file with the keys verifying player tokens")
+	authIssuer     = settings.String("authIssuer", "", "required issuer of player tokens, not checked if empty")
+	authAudience   = settings.String("authAudience", "", "required audience of player tokens, not checked if empty")
+	authHmacSecret = settings.Secret("authHmacSecret", "", "secret shared with the game backend for signing player ids")
+	authHmacMaxAge = settings.Duration("authHmacMaxAge", 15*time.Minute, "maximum age of signed player ids")
+	authAPIKeys    = settings.String("authApiKeysFile", "", "file with the hashed API keys and roles of live-ops tooling")
+
+	rateLimitUser    = settings.String("rateLimitUser", "", "submissions per player, as <burst>/<period> e.g. 20/1m, empty to disable")
+	rateLimitIP      = settings.String("rateLimitIp", "", "submissions per client IP, as <burst>/<period>, empty to disable")
+	rateLimitSession = settings.String("rateLimitSession", "", "submissions per session, as <burst>/<period>, empty to disable")
+	rateLimitStore   = settings.String("rateLimitStore", "memory", "where rate limits are kept (memory|postgres), memory limits each instance on its own")
+	clientIPHeader   = settings.String("clientIpHeader", "", "header a trusted proxy sets to the client IP, using its right-most address, the remote address is used if empty")
+
+	sessionRegistry = settings.Bool("sessionRegistry", false, "only accept feedback from the participants of sessions registered by the game backend, within their rating window")
+
+	idempotencyTTL = settings.Duration("idempotencyTtl", 24*time.Hour, "time responses to submissions with an Idempotency-Key are replayed to retries, 0 to ignore the header")
+
+	shutdownDelay       = settings.Duration("shutdownDelay", 5*time.Second, "time between reporting not ready and stopping to accept requests on shutdown")
+	shutdownGracePeriod = settings.Duration("shutdownGracePeriod", 20*time.Second, "time in-flight requests get to finish on shutdown")
 
-	dbHost     = flag.String("dbHost", "127.0.0.1", "database hostname")
-	dbPort     = flag.Int("dbPort", 5432, "database port")
-	dbUsername = flag.String("dbUsername", "db", "database username")
-	dbName     = flag.String("dbName", "db", "database name")
-	dbPassword = flag.String("dbPassword", "db", "database password")
+	dbHost     = settings.String("dbHost", "127.0.0.1", "database hostname")
+	dbPort     = settings.Int("dbPort", 5432, "database port")
+	dbUsername = settings.String("dbUsername", "db", "database username")
+	dbName     = settings.String("dbName", "db", "database name")
+	dbPassword = settings.Secret("dbPassword", "db", "database password")
+
+	dbConnectTimeout  = settings.Duration("dbConnectTimeout", time.Minute, "time to keep retrying the initial database connection")
+	dbMaxOpenConns    = settings.Int("dbMaxOpenConns", 20, "maximum number of open database connections, 0 for unlimited")
+	dbMaxIdleConns    = settings.Int("dbMaxIdleConns", 5, "maximum number of idle database connections, 0 for the database/sql default of 2")
+	dbConnMaxLifetime = settings.Duration("dbConnMaxLifetime", 30*time.Minute, "maximum lifetime of a database connection, 0 for unlimited")
+	dbMonitorInterval = settings.Duration("dbMonitorInterval", 10*time.Second, "interval of database health pings detecting outages, 0 to disable")
+
+	ingestEnabled   = settings.Bool("ingest", false, "write submissions in batches through a bounded queue")
+	ingestQueueSize = settings.Int("ingestQueueSize", 1000, "submissions waiting to be written before new ones are rejected with 503")
+	ingestWorkers   = settings.Int("ingestWorkers", 4, "workers writing batches concurrently")
+	ingestBatchSize = settings.Int("ingestBatchSize", 100, "maximum number of submissions written at once")
+
+	cacheSize   = settings.Int("cacheSize", 100, "latest entries kept in memory overall and per rating, 0 to disable the cache")
+	cacheMaxAge = settings.Duration("cacheMaxAge", 30*time.Second, "time after which cached entries are reloaded, picking up entries of other instances, 0 to never reload")
+
+	autoMigrate = settings.Bool("autoMigrate", false, "apply pending database migrations on startup")
+
+	tracing            = settings.Bool("tracing", false, "report request traces to jaeger")
+	jaegerAgent        = settings.String("jaegerAgent", "127.0.0.1:6831", "jaeger agent host:port receiving spans")
+	jaegerSampler      = settings.String("jaegerSampler", "probabilistic", "jaeger sampler type (const|probabilistic|ratelimiting|remote)")
+	jaegerSamplerParam = settings.Float64("jaegerSamplerParam", 0.01, "jaeger sampler parameter, e.g. the sampled fraction of requests")
 )
 
 func main() {
 	flag.Parse()
+	if err := settings.Load(*configFile, os.LookupEnv); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		os.Exit(2)
+	}
+	if err := validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		os.Exit(2)
+	}
+
+	if flag.Arg(0) == "config" {
+		if flag.Arg(1) != "print" {
+			fmt.Fprintf(os.Stderr, "unknown config command %q, use print\n", flag.Arg(1))
+			os.Exit(2)
+		}
+		if err := settings.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	if *versionInfo {
 		v := version.Version()
@@ -65,35 +158,390 @@ func main() {
 	defer log.Sync()
 	log.Info("starting")
 
+	if flag.Arg(0) == "migrate" {
+		if err := migrate(log, flag.Arg(1)); err != nil {
+			log.Fatal("migration failed", zap.Error(err))
+		}
+		return
+	}
+
 	if err := do(log); err != nil {
 		log.Fatal("terminating", zap.Error(err))
 	}
 }
 
 func do(log *log.Logger) error {
-	db := database.New(log)
-	err := db.Open(fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		*dbHost,
-		*dbPort,
-		*dbUsername,
-		*dbPassword,
-		*dbName,
-	))
+	ctx, stop := notifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	closer, err := newTracer(log)
 	if err != nil {
 		return err
 	}
+	defer closer.Close()
 
-	svc := feedback.New(log, db)
+	checks := health.New(log)
+	repo, err := newRepository(ctx, log, checks)
+	if err != nil {
+		return err
+	}
+	limiter, err := newLimiter(ctx, repo)
+	if err != nil {
+		return err
+	}
+	idempotency := newIdempotencyStore(ctx, repo)
+	var sessions feedback.SessionStore
+	if *sessionRegistry {
+		var ok bool
+		if sessions, ok = repo.(feedback.SessionStore); !ok {
+			return fmt.Errorf("store %q does not support the session registry", *store)
+		}
+	}
+
+	// the store is closed on shutdown, after the queue has been flushed
+	var closers []io.Closer
+	if c, ok := repo.(io.Closer); ok {
+		closers = append(closers, c)
+	}
+	if *ingestEnabled {
+		b, ok := repo.(ingest.Batcher)
+		if !ok {
+			return fmt.Errorf("store %q does not support batched ingestion", *store)
+		}
+		q := ingest.New(log, b)
+		q.Size = *ingestQueueSize
+		q.Workers = *ingestWorkers
+		q.BatchSize = *ingestBatchSize
+		q.Timeout = *requestTimeout
+		q.Start()
+		closers = append([]io.Closer{q}, closers...)
+		repo = q
+	}
+	if *cacheSize > 0 {
+		c := cache.New(log, repo, *cacheSize)
+		c.MaxAge = *cacheMaxAge
+		repo = c
+	}
+
+	svc := feedback.New(log, repo)
+	svc.Timeout = *requestTimeout
+	if svc.Auth, err = newAuthenticator(log); err != nil {
+		return err
+	}
+	svc.Limiter = limiter
+	svc.RateLimits, _ = rateLimits()
+	svc.ClientIPHeader = *clientIPHeader
+	svc.Idempotency = idempotency
+	svc.Sessions = sessions
 
 	m := http.NewServeMux()
+	m.Handle("/metrics", metrics.Handler())
+	m.Handle("/healthz", checks.Handler())
+	m.Handle("/readyz", checks.Handler())
 	m.Handle("/", svc.Handler())
 
-	log.Info("listening", zap.String("addr", ":8080"))
-	err = http.ListenAndServe(":8080", m)
+	srv := server.New(log, *listenAddr, m)
+	srv.Health = checks
+	srv.DrainDelay = *shutdownDelay
+	srv.GracePeriod = *shutdownGracePeriod
+	srv.Closers = closers
+
+	return srv.Run(ctx)
+}
+
+// notifyContext returns a copy of parent which is cancelled once one of sigs is received or stop is called
+func notifyContext(parent context.Context, sigs ...os.Signal) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(parent)
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+	go func() {
+		select {
+		case <-c:
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+	return ctx, func() {
+		signal.Stop(c)
+		cancel()
+	}
+}
+
+// newTracer registers the global jaeger tracer, which stays a noop unless tracing is enabled
+func newTracer(log *log.Logger) (io.Closer, error) {
+	cfg := config.Configuration{
+		Disabled: !*tracing,
+		Sampler: &config.SamplerConfig{
+			Type:  *jaegerSampler,
+			Param: *jaegerSamplerParam,
+		},
+		Reporter: &config.ReporterConfig{
+			LocalAgentHostPort: *jaegerAgent,
+		},
+	}
+	tracer, closer, err := cfg.New(appKey, config.Logger(jaegerzap.NewLogger(log.Logger)))
+	if err != nil {
+		return nil, err
+	}
+	opentracing.SetGlobalTracer(tracer)
+	if *tracing {
+		log.Info("tracing enabled", zap.String("agent", *jaegerAgent), zap.String("sampler", *jaegerSampler))
+	}
+	return closer, nil
+}
+
+// newAuthenticator verifying live-ops tooling by API key and players as configured by the auth settings
+func newAuthenticator(log *log.Logger) (feedback.Authenticator, error) {
+	players, err := newPlayerAuthenticator(log)
+	if err != nil || *authAPIKeys == "" {
+		return players, err
+	}
+	keys, err := auth.LoadAPIKeys(*authAPIKeys)
+	if err != nil {
+		return nil, err
+	}
+	keys.Next = players
+	return keys, nil
+}
+
+func newPlayerAuthenticator(log *log.Logger) (feedback.Authenticator, error) {
+	switch *authMode {
+	case "jwt":
+		keys, err := auth.LoadJWKS(*authJwksFile)
+		if err != nil {
+			return nil, err
+		}
+		a := auth.NewJWT(keys)
+		a.Issuer = *authIssuer
+		a.Audience = *authAudience
+		return a, nil
+	case "hmac":
+		a := auth.NewHMAC([]byte(*authHmacSecret))
+		a.MaxAge = *authHmacMaxAge
+		return a, nil
+	case "header":
+		if *authAPIKeys != "" {
+			// tooling has its own keys, so unverified requests only get to act as players
+			log.Warn("trusting the Ubi-UserId header, anyone can send feedback as any player")
+			return feedback.TrustPlayerHeader, nil
+		}
+		log.Warn("trusting the Ubi-UserId header, anyone can send feedback as any player and read all feedback")
+		return feedback.TrustHeader, nil
+	default:
+		return nil, fmt.Errorf("unknown auth %q", *authMode)
+	}
+}
+
+// rateLimits parsed from the rateLimit settings
+func rateLimits() (l feedback.RateLimits, err error) {
+	for _, s := range []struct {
+		name  string
+		value string
+		limit *ratelimit.Limit
+	}{
+		{"rateLimitUser", *rateLimitUser, &l.User},
+		{"rateLimitIp", *rateLimitIP, &l.IP},
+		{"rateLimitSession", *rateLimitSession, &l.Session},
+	} {
+		if *s.limit, err = ratelimit.ParseLimit(s.value); err != nil {
+			return l, fmt.Errorf("%s: %v", s.name, err)
+		}
+	}
+	return l, nil
+}
+
+// newLimiter keeping the buckets of the rate limits, nil if none are set.
+// Buckets in postgres are shared by all instances and kept in the database of repo.
+func newLimiter(ctx context.Context, repo feedback.Repository) (ratelimit.Store, error) {
+	limits, err := rateLimits()
 	if err != nil {
-		log.Error("server error", zap.Error(err))
+		return nil, err
+	}
+	longest := limits.User.Period
+	for _, p := range []time.Duration{limits.IP.Period, limits.Session.Period} {
+		if p > longest {
+			longest = p
+		}
+	}
+	if longest == 0 {
+		return nil, nil
+	}
+
+	switch *rateLimitStore {
+	case "memory":
+		return ratelimit.NewMemory(), nil
+	case "postgres":
+		db, ok := repo.(*database.Connection)
+		if !ok {
+			return nil, fmt.Errorf("rateLimitStore postgres requires store postgres, not %q", *store)
+		}
+		// buckets are full again after their period, so dropping them afterwards doesn't change any limit
+		go db.ExpireRateLimits(ctx, 2*longest)
+		return db, nil
+	default:
+		return nil, fmt.Errorf("unknown rateLimitStore %q", *rateLimitStore)
+	}
+}
+
+// newIdempotencyStore keeping the responses to submissions in the store of repo, nil if disabled
+func newIdempotencyStore(ctx context.Context, repo feedback.Repository) feedback.IdempotencyStore {
+	store, ok := repo.(interface {
+		feedback.IdempotencyStore
+		ExpireIdempotencyKeys(ctx context.Context, maxAge time.Duration)
+	})
+	if *idempotencyTTL == 0 || !ok {
+		return nil
+	}
+	go store.ExpireIdempotencyKeys(ctx, *idempotencyTTL)
+	return store
+}
+
+func newRepository(ctx context.Context, log *log.Logger, checks *health.Health) (feedback.Repository, error) {
+	switch *store {
+	case "memory":
+		log.Warn("using in-memory store, entries will not be persisted")
+		return memory.New(log), nil
+	case "postgres":
+		db := newConnection(log)
+		db.AutoMigrate = *autoMigrate
+		if err := db.Open(dsn()); err != nil {
+			return nil, err
+		}
+		if *dbMonitorInterval > 0 {
+			go db.Monitor(ctx, *dbMonitorInterval)
+		}
+		checks.Register("database", health.CheckerFunc(db.PingContext))
+		checks.Register("migrations", health.CheckerFunc(db.CheckMigrations))
+		return db, nil
+	default:
+		return nil, fmt.Errorf("unknown store %q", *store)
+	}
+}
+
+// newConnection to the database configured by the db settings
+func newConnection(log *log.Logger) *database.Connection {
+	db := database.New(log)
+	db.ConnectTimeout = *dbConnectTimeout
+	db.MaxOpenConns = *dbMaxOpenConns
+	db.MaxIdleConns = *dbMaxIdleConns
+	db.ConnMaxLifetime = *dbConnMaxLifetime
+	return db
+}
+
+func migrate(log *log.Logger, cmd string) error {
+	db := newConnection(log)
+	if err := db.Open(dsn()); err != nil {
 		return err
 	}
+	defer db.Close()
+
+	switch cmd {
+	case "up":
+		n, err := db.MigrateUp()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migrations\n", n)
+	case "down":
+		v, err := db.MigrateDown()
+		if err != nil {
+			return err
+		}
+		if v == 0 {
+			fmt.Println("no migrations to revert")
+			return nil
+		}
+		fmt.Printf("reverted migration %d\n", v)
+	case "status", "":
+		status, err := db.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, m := range status {
+			applied := "pending"
+			if m.Applied {
+				applied = m.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-30s %s\n", m.Version, m.Name, applied)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, use up|down|status", cmd)
+	}
+	return nil
+}
+
+// validate the loaded settings, reporting all problems at once
+func validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+	check(*maxprocs > 0, "maxprocs has to be positive")
+	check(*listenAddr != "", "listenAddr is required")
+	check(*store == "postgres" || *store == "memory", "store %q has to be postgres or memory", *store)
+	check(*requestTimeout >= 0, "requestTimeout must not be negative")
+	check(*shutdownDelay >= 0, "shutdownDelay must not be negative")
+	check(*shutdownGracePeriod >= 0, "shutdownGracePeriod must not be negative")
+	switch *authMode {
+	case "jwt":
+		check(*authJwksFile != "", "authJwksFile is required for jwt auth")
+	case "hmac":
+		check(*authHmacSecret != "", "authHmacSecret is required for hmac auth")
+		check(*authHmacMaxAge > 0, "authHmacMaxAge must be positive")
+	case "header":
+	default:
+		check(false, "auth %q has to be jwt, hmac or header", *authMode)
+	}
+	if *ingestEnabled {
+		check(*ingestQueueSize > 0, "ingestQueueSize must be positive")
+		check(*ingestWorkers > 0, "ingestWorkers must be positive")
+		check(*ingestBatchSize > 0 && *ingestBatchSize <= database.MaxBatchSize, "ingestBatchSize has to be between 1 and %d", database.MaxBatchSize)
+	}
+	if _, err := rateLimits(); err != nil {
+		check(false, "%v", err)
+	}
+	switch *rateLimitStore {
+	case "memory":
+	case "postgres":
+		check(*store == "postgres", "rateLimitStore postgres requires store postgres")
+	default:
+		check(false, "rateLimitStore %q has to be memory or postgres", *rateLimitStore)
+	}
+	check(*idempotencyTTL >= 0, "idempotencyTtl must not be negative")
+	check(*cacheSize >= 0, "cacheSize must not be negative")
+	check(*cacheMaxAge >= 0, "cacheMaxAge must not be negative")
+	if *store == "postgres" {
+		check(*dbHost != "", "dbHost is required")
+		check(*dbPort > 0 && *dbPort < 1<<16, "dbPort %d out of range", *dbPort)
+		check(*dbUsername != "", "dbUsername is required")
+		check(*dbName != "", "dbName is required")
+		check(*dbConnectTimeout >= 0, "dbConnectTimeout must not be negative")
+		check(*dbMaxOpenConns >= 0, "dbMaxOpenConns must not be negative")
+		check(*dbMaxIdleConns >= 0, "dbMaxIdleConns must not be negative")
+		check(*dbMaxOpenConns == 0 || *dbMaxIdleConns <= *dbMaxOpenConns, "dbMaxIdleConns must not exceed dbMaxOpenConns")
+		check(*dbConnMaxLifetime >= 0, "dbConnMaxLifetime must not be negative")
+		check(*dbMonitorInterval >= 0, "dbMonitorInterval must not be negative")
+	}
+	switch *jaegerSampler {
+	case "const", "probabilistic", "ratelimiting", "remote":
+	default:
+		check(false, "jaegerSampler %q has to be const, probabilistic, ratelimiting or remote", *jaegerSampler)
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
 	return nil
 }
+
+func dsn() string {
+	return fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		*dbHost,
+		*dbPort,
+		*dbUsername,
+		*dbPassword,
+		*dbName,
+	)
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/pkg/errors"
+)
+
+// APIKeyHeader carries the API key of live-ops tooling
+const APIKeyHeader = "X-Api-Key"
+
+// APIKeys authenticates live-ops tooling by the key in APIKeyHeader, passing requests without one on to Next.
+// Only the SHA-256 hashes of the keys are configured, so the key file doesn't leak the keys themselves.
+type APIKeys struct {
+	keys map[[sha256.Size]byte]feedback.Identity
+	// Next authenticates requests without API key, usually those of players
+	Next feedback.Authenticator
+}
+
+// LoadAPIKeys reads the keys from the file at path
+func LoadAPIKeys(path string) (*APIKeys, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := ParseAPIKeys(data)
+	if err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+	return keys, nil
+}
+
+// ParseAPIKeys from a JSON list of clients, each with a name, role and the hex encoded SHA-256 hash of its key:
+//
+//	{"keys": [{"name": "dashboard", "role": "reader", "sha256": "..."}]}
+func ParseAPIKeys(data []byte) (*APIKeys, error) {
+	var file struct {
+		Keys []struct {
+			Name   string `json:"name"`
+			Role   string `json:"role"`
+			SHA256 string `json:"sha256"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	a := &APIKeys{keys: make(map[[sha256.Size]byte]feedback.Identity)}
+	for i, k := range file.Keys {
+		id := feedback.Identity{UserID: k.Name, Role: feedback.Role(k.Role)}
+		if id.UserID == "" {
+			return nil, errors.Errorf("key %d: missing name", i)
+		}
+		if !id.Role.Valid() {
+			return nil, errors.Errorf("key %q: unknown role %q", k.Name, k.Role)
+		}
+		decoded, err := hex.DecodeString(k.SHA256)
+		if err != nil || len(decoded) != sha256.Size {
+			return nil, errors.Errorf("key %q: sha256 has to be a hex encoded SHA-256 hash", k.Name)
+		}
+		var hash [sha256.Size]byte
+		copy(hash[:], decoded)
+		if _, ok := a.keys[hash]; ok {
+			return nil, errors.Errorf("key %q: duplicate key", k.Name)
+		}
+		a.keys[hash] = id
+	}
+	return a, nil
+}
+
+// Authenticate r by its API key, or by Next if it has none
+func (a *APIKeys) Authenticate(r *http.Request) (feedback.Identity, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		if a.Next == nil {
+			return feedback.Identity{}, feedback.ErrUnauthenticated
+		}
+		return a.Next.Authenticate(r)
+	}
+	id, ok := a.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return feedback.Identity{}, feedback.ErrUnauthenticated.Wrap(errors.New("unknown API key"))
+	}
+	return id, nil
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/pkg/errors"
+)
+
+func hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    string
+		wantErr bool
+	}{
+		{"valid", `{"keys": [{"name": "dashboard", "role": "reader", "sha256": "` + hash("a") + `"}]}`, false},
+		{"empty", `{"keys": []}`, false},
+		{"invalidJSON", `{"keys": [`, true},
+		{"missingName", `{"keys": [{"role": "reader", "sha256": "` + hash("a") + `"}]}`, true},
+		{"unknownRole", `{"keys": [{"name": "x", "role": "root", "sha256": "` + hash("a") + `"}]}`, true},
+		{"plainKey", `{"keys": [{"name": "x", "role": "reader", "sha256": "a"}]}`, true},
+		{"longHash", `{"keys": [{"name": "x", "role": "reader", "sha256": "` + hash("a") + `00"}]}`, true},
+		{"duplicate", `{"keys": [{"name": "x", "role": "reader", "sha256": "` + hash("a") + `"}, {"name": "y", "role": "admin", "sha256": "` + hash("a") + `"}]}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseAPIKeys([]byte(tt.keys)); (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAPIKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAPIKeys_Authenticate(t *testing.T) {
+	keys, err := ParseAPIKeys([]byte(`{"keys": [
+		{"name": "dashboard", "role": "reader", "sha256": "` + hash("reader-key") + `"},
+		{"name": "support", "role": "moderator", "sha256": "` + hash("moderator-key") + `"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := feedback.Identity{UserID: "p1", Role: feedback.RolePlayer}
+
+	tests := []struct {
+		name    string
+		key     string
+		next    feedback.Authenticator
+		want    feedback.Identity
+		wantErr bool
+	}{
+		{"reader", "reader-key", nil, feedback.Identity{UserID: "dashboard", Role: feedback.RoleReader}, false},
+		{"moderator", "moderator-key", nil, feedback.Identity{UserID: "support", Role: feedback.RoleModerator}, false},
+		{"unknown", "other", nil, feedback.Identity{}, true},
+		{"next", "", feedback.TrustHeader, feedback.Identity{Role: feedback.RoleAdmin}, false},
+		{"nextPlayer", "", feedback.TrustPlayerHeader, feedback.Identity{Role: feedback.RolePlayer}, false},
+		{"noNext", "", nil, feedback.Identity{}, true},
+		// an invalid key is not passed on to the player authentication
+		{"unknownWithNext", "other", feedback.AuthenticatorFunc(func(*http.Request) (feedback.Identity, error) { return player, nil }), feedback.Identity{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys.Next = tt.next
+			r := httptest.NewRequest("GET", "/list", nil)
+			if tt.key != "" {
+				r.Header.Set(APIKeyHeader, tt.key)
+			}
+			id, err := keys.Authenticate(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && errors.Cause(err) != feedback.ErrUnauthenticated {
+				t.Fatalf("Authenticate() error = %v want %v", err, feedback.ErrUnauthenticated)
+			}
+			if id != tt.want {
+				t.Fatalf("Authenticate() = %+v want %+v", id, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Package auth verifies player identities issued by the game backend, implementing feedback.Authenticator.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/pkg/errors"
+)
+
+const (
+	// TimestampHeader carries the unix time the identity was signed at
+	TimestampHeader = "Ubi-Timestamp"
+	// SignatureHeader carries the hex encoded signature of user id and timestamp
+	SignatureHeader = "Ubi-Signature"
+)
+
+// HMAC authenticates requests carrying the user id in feedback.UserIDHeader along with a timestamp and
+// an HMAC-SHA256 signature over both, created by the game backend with a secret shared with this service.
+type HMAC struct {
+	Secret []byte
+	// MaxAge of signatures, limiting the time a leaked signature can be used
+	MaxAge time.Duration
+
+	now func() time.Time
+}
+
+// NewHMAC authenticator verifying signatures created with secret
+func NewHMAC(secret []byte) *HMAC {
+	return &HMAC{
+		Secret: secret,
+		MaxAge: 15 * time.Minute,
+		now:    time.Now,
+	}
+}
+
+// Sign userID at t with secret, returning the values of TimestampHeader and SignatureHeader
+func Sign(secret []byte, userID string, t time.Time) (timestamp, signature string) {
+	timestamp = strconv.FormatInt(t.Unix(), 10)
+	return timestamp, hex.EncodeToString(mac(secret, userID, timestamp))
+}
+
+func mac(secret []byte, userID, timestamp string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(userID + "\n" + timestamp))
+	return h.Sum(nil)
+}
+
+// Authenticate r by its signed headers
+func (a *HMAC) Authenticate(r *http.Request) (feedback.Identity, error) {
+	userID := r.Header.Get(feedback.UserIDHeader)
+	timestamp := r.Header.Get(TimestampHeader)
+	signature, err := hex.DecodeString(r.Header.Get(SignatureHeader))
+	if userID == "" || timestamp == "" || len(signature) == 0 || err != nil {
+		return feedback.Identity{}, feedback.ErrUnauthenticated
+	}
+	if !hmac.Equal(signature, mac(a.Secret, userID, timestamp)) {
+		return feedback.Identity{}, feedback.ErrUnauthenticated.Wrap(errors.New("invalid signature"))
+	}
+
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return feedback.Identity{}, feedback.ErrUnauthenticated.Wrap(err)
+	}
+	// allow for clocks being slightly off in both directions
+	if age := a.now().Sub(time.Unix(sec, 0)); age > a.MaxAge || age < -time.Minute {
+		return feedback.Identity{}, feedback.ErrUnauthenticated.Wrap(errors.Errorf("signature created %v ago", age))
+	}
+	return feedback.Identity{UserID: userID, Role: feedback.RolePlayer}, nil
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/pkg/errors"
+)
+
+func TestHMAC_Authenticate(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1500000000, 0)
+	tests := []struct {
+		name      string
+		secret    []byte
+		user      string
+		signedAt  time.Time
+		signature string
+		wantErr   bool
+	}{
+		{"valid", secret, "u1", now.Add(-time.Minute), "", false},
+		{"missing", nil, "", now, "", true},
+		{"wrongSecret", []byte("other"), "u1", now, "", true},
+		{"tampered", secret, "u1", now, "00ff", true},
+		{"notHex", secret, "u1", now, "xyz", true},
+		{"expired", secret, "u1", now.Add(-time.Hour), "", true},
+		{"future", secret, "u1", now.Add(time.Hour), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewHMAC(secret)
+			a.now = func() time.Time { return now }
+
+			r := httptest.NewRequest("POST", "/session", nil)
+			if tt.secret != nil {
+				timestamp, signature := Sign(tt.secret, tt.user, tt.signedAt)
+				if tt.signature != "" {
+					signature = tt.signature
+				}
+				r.Header.Set(feedback.UserIDHeader, tt.user)
+				r.Header.Set(TimestampHeader, timestamp)
+				r.Header.Set(SignatureHeader, signature)
+			}
+
+			id, err := a.Authenticate(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && errors.Cause(err) != feedback.ErrUnauthenticated {
+				t.Fatalf("Authenticate() error = %v want %v", err, feedback.ErrUnauthenticated)
+			}
+			if err == nil && id.UserID != tt.user {
+				t.Fatalf("Authenticate() user = %q want %q", id.UserID, tt.user)
+			}
+		})
+	}
+}
+
+func TestHMAC_userBound(t *testing.T) {
+	secret := []byte("secret")
+	timestamp, signature := Sign(secret, "u1", time.Now())
+
+	r := httptest.NewRequest("POST", "/session", nil)
+	r.Header.Set(feedback.UserIDHeader, "u2")
+	r.Header.Set(TimestampHeader, timestamp)
+	r.Header.Set(SignatureHeader, signature)
+	if _, err := NewHMAC(secret).Authenticate(r); err == nil {
+		t.Fatal("signature of one user accepted for another")
+	}
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseJWKS(t *testing.T) {
+	tests := []struct {
+		name    string
+		jwks    string
+		wantErr bool
+	}{
+		{"oct", `{"keys": [{"kty": "oct", "k": "c2VjcmV0"}]}`, false},
+		{"rsa", `{"keys": [{"kty": "RSA", "kid": "a", "n": "AQAB", "e": "AQAB"}]}`, false},
+		{"invalidJSON", `{"keys": [`, true},
+		{"empty", `{"keys": []}`, true},
+		{"onlyEncryption", `{"keys": [{"kty": "oct", "use": "enc", "k": "c2VjcmV0"}]}`, true},
+		{"unsupported", `{"keys": [{"kty": "EC", "kid": "a"}]}`, true},
+		{"invalidSecret", `{"keys": [{"kty": "oct", "k": "!"}]}`, true},
+		{"missingExponent", `{"keys": [{"kty": "RSA", "n": "AQAB"}]}`, true},
+		{"duplicateKid", `{"keys": [{"kty": "oct", "kid": "a", "k": "YQ"}, {"kty": "oct", "kid": "a", "k": "Yg"}]}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseJWKS([]byte(tt.jwks)); (err != nil) != tt.wantErr {
+				t.Fatalf("ParseJWKS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadJWKS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jwks.json")
+	if err := ioutil.WriteFile(path, []byte(`{"keys": [{"kty": "oct", "kid": "a", "k": "c2VjcmV0"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadJWKS(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, ok := keys.key(""); !ok || string(key.([]byte)) != "secret" {
+		t.Fatalf("key() = %v, %v", key, ok)
+	}
+	if _, err := LoadJWKS(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatal("LoadJWKS() should fail for a missing file")
+	}
+}
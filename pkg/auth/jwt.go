@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/pkg/errors"
+)
+
+// JWT authenticates requests by a bearer token (RFC 7519) signed with HS256 or RS256,
+// taking the user id from its subject and the role from the role claim, defaulting to player.
+type JWT struct {
+	Keys *KeySet
+	// Issuer and Audience the token has to be issued by and for, not checked if empty
+	Issuer   string
+	Audience string
+	// Leeway for clocks being slightly off when checking exp and nbf
+	Leeway time.Duration
+
+	now func() time.Time
+}
+
+// NewJWT authenticator verifying tokens with keys
+func NewJWT(keys *KeySet) *JWT {
+	return &JWT{
+		Keys:   keys,
+		Leeway: time.Minute,
+		now:    time.Now,
+	}
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	Role      string   `json:"role"`
+}
+
+// audience claim, which is either a single string or a list
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+func (a audience) contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate r by the token in its Authorization header
+func (a *JWT) Authenticate(r *http.Request) (feedback.Identity, error) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return feedback.Identity{}, feedback.ErrUnauthenticated
+	}
+	c, err := a.verify(strings.TrimPrefix(auth, "Bearer "))
+	if err != nil {
+		return feedback.Identity{}, feedback.ErrUnauthenticated.Wrap(err)
+	}
+	role := feedback.RolePlayer
+	if c.Role != "" {
+		role = feedback.Role(c.Role)
+	}
+	if !role.Valid() {
+		return feedback.Identity{}, feedback.ErrUnauthenticated.Wrap(errors.Errorf("unknown role %q", c.Role))
+	}
+	return feedback.Identity{UserID: c.Subject, Role: role}, nil
+}
+
+// verify the signature and claims of token
+func (a *JWT) verify(token string) (claims, error) {
+	var c claims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return c, errors.New("malformed token")
+	}
+	var h header
+	if err := decodePart(parts[0], &h); err != nil {
+		return c, errors.Wrap(err, "header")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return c, errors.Wrap(err, "signature")
+	}
+	key, ok := a.Keys.key(h.Kid)
+	if !ok {
+		return c, errors.Errorf("unknown key %q", h.Kid)
+	}
+	if err := verifySignature(h.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return c, err
+	}
+
+	if err := decodePart(parts[1], &c); err != nil {
+		return c, errors.Wrap(err, "claims")
+	}
+	now := a.now()
+	switch {
+	case c.Subject == "":
+		return c, errors.New("missing subject")
+	case c.ExpiresAt == 0:
+		return c, errors.New("missing expiry")
+	case now.After(time.Unix(c.ExpiresAt, 0).Add(a.Leeway)):
+		return c, errors.New("token expired")
+	case c.NotBefore != 0 && now.Before(time.Unix(c.NotBefore, 0).Add(-a.Leeway)):
+		return c, errors.New("token not valid yet")
+	case a.Issuer != "" && c.Issuer != a.Issuer:
+		return c, errors.Errorf("unexpected issuer %q", c.Issuer)
+	case a.Audience != "" && !c.Audience.contains(a.Audience):
+		return c, errors.Errorf("unexpected audience %q", c.Audience)
+	}
+	return c, nil
+}
+
+func decodePart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verifySignature of input. The algorithm has to match the type of key, so a token can't
+// pass off a public RSA key as HMAC secret.
+func verifySignature(alg string, key interface{}, input string, sig []byte) error {
+	switch k := key.(type) {
+	case []byte:
+		if alg != "HS256" {
+			break
+		}
+		h := hmac.New(sha256.New, k)
+		h.Write([]byte(input))
+		if !hmac.Equal(sig, h.Sum(nil)) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			break
+		}
+		digest := sha256.Sum256([]byte(input))
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig); err != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return errors.Errorf("algorithm %q not supported by key", alg)
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/pkg/errors"
+)
+
+// sign a token with key, which is either an HMAC secret or an RSA private key
+func sign(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	t.Helper()
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	input := encode(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encode(claims)
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		h := hmac.New(sha256.New, k)
+		h.Write([]byte(input))
+		sig = h.Sum(nil)
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(input))
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestJWT_Authenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte("secret")
+	pub := rsaKey.PublicKey.N.Bytes()
+	jwks := `{"keys": [
+		{"kty": "oct", "kid": "hs", "k": "` + b64(secret) + `"},
+		{"kty": "RSA", "kid": "rs", "use": "sig", "n": "` + b64(pub) + `", "e": "` + b64(big.NewInt(int64(rsaKey.E)).Bytes()) + `"},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"}
+	]}`
+	keys, err := ParseJWKS([]byte(jwks))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1500000000, 0)
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{"sub": "u1", "exp": now.Add(time.Hour).Unix(), "iss": "game", "aud": []string{"feedback"}}
+	}
+	with := func(k string, v interface{}) map[string]interface{} {
+		c := valid()
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+		return c
+	}
+	tests := []struct {
+		name     string
+		token    string
+		wantErr  bool
+		wantRole feedback.Role
+	}{
+		{"hs256", sign(t, "HS256", "hs", secret, valid()), false, feedback.RolePlayer},
+		{"rs256", sign(t, "RS256", "rs", rsaKey, valid()), false, feedback.RolePlayer},
+		{"audienceString", sign(t, "HS256", "hs", secret, with("aud", "feedback")), false, feedback.RolePlayer},
+		{"withinLeeway", sign(t, "HS256", "hs", secret, with("exp", now.Add(-30*time.Second).Unix())), false, feedback.RolePlayer},
+		{"role", sign(t, "RS256", "rs", rsaKey, with("role", "reader")), false, feedback.RoleReader},
+		{"unknownRole", sign(t, "RS256", "rs", rsaKey, with("role", "root")), true, ""},
+		{"missing", "", true, ""},
+		{"malformed", "a.b", true, ""},
+		{"wrongSecret", sign(t, "HS256", "hs", []byte("other"), valid()), true, ""},
+		{"wrongKey", sign(t, "RS256", "rs", otherKey, valid()), true, ""},
+		{"unknownKid", sign(t, "HS256", "other", secret, valid()), true, ""},
+		{"encryptionKey", sign(t, "RS256", "enc", rsaKey, valid()), true, ""},
+		{"none", strings.TrimSuffix(sign(t, "none", "hs", []byte{}, valid()), "."+b64(nil)) + ".", true, ""},
+		// the public key must not be accepted as HMAC secret
+		{"algConfusion", sign(t, "HS256", "rs", pub, valid()), true, ""},
+		{"expired", sign(t, "HS256", "hs", secret, with("exp", now.Add(-time.Hour).Unix())), true, ""},
+		{"noExpiry", sign(t, "HS256", "hs", secret, with("exp", nil)), true, ""},
+		{"notBefore", sign(t, "HS256", "hs", secret, with("nbf", now.Add(time.Hour).Unix())), true, ""},
+		{"noSubject", sign(t, "HS256", "hs", secret, with("sub", nil)), true, ""},
+		{"issuer", sign(t, "HS256", "hs", secret, with("iss", "other")), true, ""},
+		{"audience", sign(t, "HS256", "hs", secret, with("aud", "other")), true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewJWT(keys)
+			a.Issuer = "game"
+			a.Audience = "feedback"
+			a.now = func() time.Time { return now }
+
+			r := httptest.NewRequest("POST", "/session", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			id, err := a.Authenticate(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && errors.Cause(err) != feedback.ErrUnauthenticated {
+				t.Fatalf("Authenticate() error = %v want %v", err, feedback.ErrUnauthenticated)
+			}
+			if err == nil && (id.UserID != "u1" || id.Role != tt.wantRole) {
+				t.Fatalf("Authenticate() = %+v want u1 as %s", id, tt.wantRole)
+			}
+		})
+	}
+}
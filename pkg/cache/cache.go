@@ -0,0 +1,162 @@
+// Package cache keeps the latest feedback entries in memory in front of a feedback.Repository.
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/playnet-public/libs/log"
+	"go.uber.org/zap"
+)
+
+// Cache decorating a feedback.Repository, serving GetLatest and GetLatestFiltered from ring buffers
+// of the newest entries overall and per rating. Requests not fitting into the buffers and all other
+// methods are passed on to the underlying Repository.
+type Cache struct {
+	feedback.Repository
+	*log.Logger
+
+	// MaxAge after which buffers are loaded from the Repository again, picking up entries added by
+	// other instances of the service. Buffers never expire if zero.
+	MaxAge time.Duration
+
+	size int
+	now  func() time.Time
+
+	mu       sync.Mutex
+	all      *ring
+	byRating map[int8]*ring
+	// generation is increased by every change, so loads racing with them are discarded
+	generation uint64
+}
+
+const (
+	minRating = 1
+	maxRating = 5
+)
+
+// New Cache in front of repo, keeping the newest size entries overall and per rating
+func New(log *log.Logger, repo feedback.Repository, size int) *Cache {
+	log = log.WithFields(zap.String("component", "cache"))
+	c := &Cache{
+		Repository: repo,
+		Logger:     log,
+		size:       size,
+		now:        time.Now,
+		all:        newRing(size),
+		byRating:   make(map[int8]*ring),
+	}
+	for r := int8(minRating); r <= maxRating; r++ {
+		c.byRating[r] = newRing(size)
+	}
+	return c
+}
+
+// Add entry to the Repository and the loaded buffers. The entry is only read back from the
+// Repository if a buffer has to hold it.
+func (c *Cache) Add(ctx context.Context, entry feedback.Entry) error {
+	if err := c.Repository.Add(ctx, entry); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	r, ok := c.byRating[entry.Rating]
+	if !c.all.loaded && !(ok && r.loaded) {
+		c.generation++
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+
+	// id and creation time are assigned by the Repository
+	stored, err := c.Repository.Get(ctx, entry.SessionID, entry.UserID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if err != nil {
+		c.Warn("reading added entry failed, invalidating cache", zap.Error(err))
+		c.invalidate()
+		return nil
+	}
+	if c.all.loaded {
+		c.all.insert(stored)
+	}
+	if r, ok := c.byRating[stored.Rating]; ok && r.loaded {
+		r.insert(stored)
+	}
+	return nil
+}
+
+// Update entry in the Repository, invalidating the buffers as the entry might move between ratings
+func (c *Cache) Update(ctx context.Context, entry feedback.Entry) error {
+	err := c.Repository.Update(ctx, entry)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.invalidate()
+	return err
+}
+
+// GetLatest n entries, from memory if possible
+func (c *Cache) GetLatest(ctx context.Context, n uint) ([]feedback.Entry, error) {
+	return c.latest("latest", c.all, n, func(limit uint) ([]feedback.Entry, error) {
+		return c.Repository.GetLatest(ctx, limit)
+	})
+}
+
+// GetLatestFiltered n entries by rating, from memory if possible
+func (c *Cache) GetLatestFiltered(ctx context.Context, n uint, filter int) ([]feedback.Entry, error) {
+	if filter < minRating || filter > maxRating {
+		misses.Inc("latest_filtered")
+		return c.Repository.GetLatestFiltered(ctx, n, filter)
+	}
+	return c.latest("latest_filtered", c.byRating[int8(filter)], n, func(limit uint) ([]feedback.Entry, error) {
+		return c.Repository.GetLatestFiltered(ctx, limit, filter)
+	})
+}
+
+// latest n entries from r, loading r with get if it is not loaded or expired
+func (c *Cache) latest(query string, r *ring, n uint, get func(uint) ([]feedback.Entry, error)) ([]feedback.Entry, error) {
+	c.mu.Lock()
+	if c.MaxAge > 0 && r.loaded && c.now().Sub(r.loadedAt) > c.MaxAge {
+		r.invalidate()
+	}
+	entries, ok := r.latest(n)
+	generation := c.generation
+	c.mu.Unlock()
+	if ok {
+		hits.Inc(query)
+		return entries, nil
+	}
+	misses.Inc(query)
+
+	if n > uint(c.size) {
+		return get(n)
+	}
+	entries, err := get(uint(c.size))
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		r.reset(entries, c.now())
+	}
+	c.mu.Unlock()
+
+	if uint(len(entries)) > n {
+		entries = entries[:n]
+	}
+	return entries, nil
+}
+
+func (c *Cache) invalidate() {
+	c.all.invalidate()
+	for _, r := range c.byRating {
+		r.invalidate()
+	}
+}
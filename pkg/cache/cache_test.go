@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback/feedbacktest"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/memory"
+	"github.com/playnet-public/libs/log"
+)
+
+func TestCache_Repository(t *testing.T) {
+	feedbacktest.TestRepository(t, func(t *testing.T) feedback.Repository {
+		return New(log.NewNop(), memory.New(log.NewNop()), 3)
+	})
+}
+
+// countingRepository counts the latest queries and entry reads reaching the store
+type countingRepository struct {
+	feedback.Repository
+	queries int
+	gets    int
+}
+
+func (r *countingRepository) Get(ctx context.Context, sessionID, userID string) (feedback.Entry, error) {
+	r.gets++
+	return r.Repository.Get(ctx, sessionID, userID)
+}
+
+func (r *countingRepository) GetLatest(ctx context.Context, n uint) ([]feedback.Entry, error) {
+	r.queries++
+	return r.Repository.GetLatest(ctx, n)
+}
+
+func (r *countingRepository) GetLatestFiltered(ctx context.Context, n uint, filter int) ([]feedback.Entry, error) {
+	r.queries++
+	return r.Repository.GetLatestFiltered(ctx, n, filter)
+}
+
+func add(t *testing.T, repo feedback.Repository, user string, rating int8) {
+	t.Helper()
+	if err := repo.Add(context.Background(), feedback.Entry{SessionID: "s", UserID: user, Rating: rating}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCache_hits(t *testing.T) {
+	store := &countingRepository{Repository: memory.New(log.NewNop())}
+	c := New(log.NewNop(), store, 3)
+	ctx := context.Background()
+	add(t, c, "1", 5)
+	add(t, c, "2", 4)
+
+	tests := []struct {
+		name    string
+		get     func() ([]feedback.Entry, error)
+		queries int
+		want    []string
+	}{
+		{"load", func() ([]feedback.Entry, error) { return c.GetLatest(ctx, 2) }, 1, []string{"2", "1"}},
+		{"hit", func() ([]feedback.Entry, error) { return c.GetLatest(ctx, 1) }, 1, []string{"2"}},
+		{"hitAll", func() ([]feedback.Entry, error) { return c.GetLatest(ctx, 10) }, 1, []string{"2", "1"}},
+		{"filteredLoad", func() ([]feedback.Entry, error) { return c.GetLatestFiltered(ctx, 1, 5) }, 2, []string{"1"}},
+		{"filteredHit", func() ([]feedback.Entry, error) { return c.GetLatestFiltered(ctx, 2, 5) }, 2, []string{"1"}},
+		{"invalidRating", func() ([]feedback.Entry, error) { return c.GetLatestFiltered(ctx, 2, 9) }, 3, []string{}},
+	}
+	for _, tt := range tests {
+		entries, err := tt.get()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if store.queries != tt.queries {
+			t.Errorf("%s: store queried %d times want %d", tt.name, store.queries, tt.queries)
+		}
+		if got := ids(entries); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCache_Add(t *testing.T) {
+	store := &countingRepository{Repository: memory.New(log.NewNop())}
+	c := New(log.NewNop(), store, 3)
+	ctx := context.Background()
+	if _, err := c.GetLatest(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+	for i, user := range []string{"1", "2", "3", "4"} {
+		add(t, c, user, int8(i%2+1))
+	}
+
+	entries, err := c.GetLatest(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(entries), []string{"4", "3", "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetLatest() = %v want %v", got, want)
+	}
+	if entries[0].CreatedAt.IsZero() {
+		t.Error("cached entry lacks the creation time assigned by the store")
+	}
+	// the full ring no longer holds all entries
+	if _, err := c.GetLatest(ctx, 4); err != nil {
+		t.Fatal(err)
+	}
+	if store.queries != 2 {
+		t.Errorf("store queried %d times want 2", store.queries)
+	}
+	if store.gets != 4 {
+		t.Errorf("added entries read back %d times want 4", store.gets)
+	}
+}
+
+func TestCache_AddUnloaded(t *testing.T) {
+	store := &countingRepository{Repository: memory.New(log.NewNop())}
+	c := New(log.NewNop(), store, 3)
+	add(t, c, "1", 1)
+	if store.gets != 0 {
+		t.Errorf("added entry read back %d times without a loaded buffer", store.gets)
+	}
+	entries, err := c.GetLatest(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(entries), []string{"1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetLatest() = %v want %v", got, want)
+	}
+}
+
+func TestCache_Update(t *testing.T) {
+	c := New(log.NewNop(), memory.New(log.NewNop()), 3)
+	ctx := context.Background()
+	add(t, c, "1", 1)
+	if _, err := c.GetLatestFiltered(ctx, 3, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Update(ctx, feedback.Entry{SessionID: "s", UserID: "1", Rating: 2}); err != nil {
+		t.Fatal(err)
+	}
+	for rating, want := range map[int][]string{1: {}, 2: {"1"}} {
+		entries, err := c.GetLatestFiltered(ctx, 3, rating)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(entries); !reflect.DeepEqual(got, want) {
+			t.Errorf("GetLatestFiltered(%d) = %v want %v", rating, got, want)
+		}
+	}
+}
+
+func TestCache_MaxAge(t *testing.T) {
+	store := &countingRepository{Repository: memory.New(log.NewNop())}
+	c := New(log.NewNop(), store, 3)
+	c.MaxAge = time.Minute
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for _, step := range []time.Duration{0, time.Second, 2 * time.Minute} {
+		now = now.Add(step)
+		if _, err := c.GetLatest(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if store.queries != 2 {
+		t.Errorf("store queried %d times want 2", store.queries)
+	}
+}
+
+func ids(entries []feedback.Entry) []string {
+	ids := []string{}
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
@@ -0,0 +1,16 @@
+package cache
+
+import "github.com/kwiesmueller/ubisoft-backend-interview/pkg/metrics"
+
+var (
+	hits = metrics.NewCounterVec(
+		"feedback_cache_hits_total",
+		"Queries answered from the cache by query.",
+		"query",
+	)
+	misses = metrics.NewCounterVec(
+		"feedback_cache_misses_total",
+		"Queries passed on to the repository by query.",
+		"query",
+	)
+)
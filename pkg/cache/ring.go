@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+)
+
+// ring buffer of the newest entries, ordered newest first by id
+type ring struct {
+	entries []feedback.Entry
+	// head is the index of the newest entry
+	head  int
+	count int
+
+	// loaded is set while the ring holds the newest entries of the store
+	loaded   bool
+	loadedAt time.Time
+}
+
+func newRing(size int) *ring {
+	return &ring{entries: make([]feedback.Entry, size)}
+}
+
+// at returns the i-th newest entry
+func (r *ring) at(i int) *feedback.Entry {
+	return &r.entries[(r.head+i)%len(r.entries)]
+}
+
+// reset the ring to entries, which are ordered newest first
+func (r *ring) reset(entries []feedback.Entry, now time.Time) {
+	r.head, r.count = 0, 0
+	for i := 0; i < len(entries) && i < len(r.entries); i++ {
+		r.entries[i] = entries[i]
+		r.count++
+	}
+	r.loaded = true
+	r.loadedAt = now
+}
+
+// invalidate the ring until it is loaded again
+func (r *ring) invalidate() {
+	r.loaded = false
+}
+
+// insert e, dropping the oldest entry if the ring is full.
+// Entries older than all entries of a full ring are ignored.
+func (r *ring) insert(e feedback.Entry) {
+	size := len(r.entries)
+	if r.count == size && newer(r.at(r.count-1).ID, e.ID) {
+		return
+	}
+	r.head = (r.head - 1 + size) % size
+	*r.at(0) = e
+	if r.count < size {
+		r.count++
+	}
+	// concurrent adds may finish out of order
+	for i := 0; i+1 < r.count && newer(r.at(i+1).ID, r.at(i).ID); i++ {
+		*r.at(i), *r.at(i + 1) = *r.at(i + 1), *r.at(i)
+	}
+}
+
+// latest n entries if the ring holds them
+func (r *ring) latest(n uint) ([]feedback.Entry, bool) {
+	// a ring that isn't full holds all entries of the store
+	if !r.loaded || (n > uint(r.count) && r.count == len(r.entries)) {
+		return nil, false
+	}
+	if n > uint(r.count) {
+		n = uint(r.count)
+	}
+	entries := make([]feedback.Entry, n)
+	for i := range entries {
+		entries[i] = *r.at(i)
+	}
+	return entries, true
+}
+
+// newer reports whether the id a was assigned after b, both being decimal numbers
+func newer(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return a > b
+}
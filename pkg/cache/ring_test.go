@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+)
+
+func TestRing_insert(t *testing.T) {
+	tests := []struct {
+		name   string
+		insert []int
+		want   []string
+	}{
+		{"empty", nil, []string{}},
+		{"partial", []int{1, 2}, []string{"2", "1"}},
+		{"wrap", []int{1, 2, 3, 4, 5}, []string{"5", "4", "3"}},
+		{"outOfOrder", []int{1, 3, 2}, []string{"3", "2", "1"}},
+		{"tooOld", []int{8, 9, 10, 7}, []string{"10", "9", "8"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRing(3)
+			r.reset(nil, time.Now())
+			for _, id := range tt.insert {
+				r.insert(feedback.Entry{ID: strconv.Itoa(id)})
+			}
+			entries, ok := r.latest(3)
+			if !ok {
+				t.Fatal("latest() missed on a loaded ring")
+			}
+			if got := ids(entries); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("latest() = %v want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRing_latest(t *testing.T) {
+	full := []feedback.Entry{{ID: "3"}, {ID: "2"}, {ID: "1"}}
+	tests := []struct {
+		name    string
+		loaded  []feedback.Entry
+		n       uint
+		wantHit bool
+	}{
+		{"notLoaded", nil, 1, false},
+		{"fits", full, 2, true},
+		{"exceedsFull", full, 4, false},
+		{"exceedsPartial", full[:2], 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRing(3)
+			if tt.loaded != nil {
+				r.reset(tt.loaded, time.Now())
+			}
+			if _, ok := r.latest(tt.n); ok != tt.wantHit {
+				t.Errorf("latest(%d) hit = %v want %v", tt.n, ok, tt.wantHit)
+			}
+		})
+	}
+}
+
+func TestNewer(t *testing.T) {
+	if !newer("10", "9") || newer("9", "10") || !newer("2", "1") || newer("1", "1") {
+		t.Fatal("newer() doesn't compare ids numerically")
+	}
+}
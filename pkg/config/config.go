@@ -0,0 +1,171 @@
+// Package config layers settings from defaults, a config file, environment variables and command line flags.
+//
+// Settings are regular flags registered through a Set. Values given on the command line take precedence
+// over the environment (dbPassword is read from FEEDBACK_DB_PASSWORD with the prefix FEEDBACK_), which takes
+// precedence over the config file, falling back to the flag defaults.
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Source a setting got its value from
+type Source string
+
+// Sources in ascending precedence
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+const redacted = "********"
+
+// Set of settings registered on a flag.FlagSet
+type Set struct {
+	fs      *flag.FlagSet
+	prefix  string
+	names   []string
+	secrets map[string]bool
+	sources map[string]Source
+}
+
+// New Set registering its settings on fs, reading them from environment variables starting with prefix.
+// The prefix keeps settings apart from variables set by others, like the DB_PORT Kubernetes sets for a db service.
+func New(fs *flag.FlagSet, prefix string) *Set {
+	return &Set{
+		fs:      fs,
+		prefix:  prefix,
+		secrets: make(map[string]bool),
+		sources: make(map[string]Source),
+	}
+}
+
+func (s *Set) add(name string) {
+	s.names = append(s.names, name)
+	s.sources[name] = SourceDefault
+}
+
+// String setting
+func (s *Set) String(name, value, usage string) *string {
+	s.add(name)
+	return s.fs.String(name, value, usage)
+}
+
+// Secret string setting, redacted when printed
+func (s *Set) Secret(name, value, usage string) *string {
+	s.secrets[name] = true
+	return s.String(name, value, usage)
+}
+
+// Int setting
+func (s *Set) Int(name string, value int, usage string) *int {
+	s.add(name)
+	return s.fs.Int(name, value, usage)
+}
+
+// Bool setting
+func (s *Set) Bool(name string, value bool, usage string) *bool {
+	s.add(name)
+	return s.fs.Bool(name, value, usage)
+}
+
+// Float64 setting
+func (s *Set) Float64(name string, value float64, usage string) *float64 {
+	s.add(name)
+	return s.fs.Float64(name, value, usage)
+}
+
+// Duration setting
+func (s *Set) Duration(name string, value time.Duration, usage string) *time.Duration {
+	s.add(name)
+	return s.fs.Duration(name, value, usage)
+}
+
+// Load all settings not given on the command line from the environment or the config file at path,
+// which is skipped if empty. The flag set has to be parsed before.
+func (s *Set) Load(path string, lookupEnv func(string) (string, bool)) error {
+	file := make(map[string]string)
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return errors.Wrap(err, "open config file")
+		}
+		defer f.Close()
+		file, err = ParseFile(f)
+		if err != nil {
+			return errors.Wrapf(err, "config file %s", path)
+		}
+		for key := range file {
+			if _, ok := s.sources[key]; !ok {
+				return errors.Errorf("config file %s: unknown setting %q", path, key)
+			}
+		}
+	}
+
+	explicit := make(map[string]bool)
+	s.fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	for _, name := range s.names {
+		if explicit[name] {
+			s.sources[name] = SourceFlag
+			continue
+		}
+		if v, ok := lookupEnv(s.EnvName(name)); ok {
+			if err := s.fs.Set(name, v); err != nil {
+				return errors.Wrapf(err, "environment variable %s", s.EnvName(name))
+			}
+			s.sources[name] = SourceEnv
+			continue
+		}
+		if v, ok := file[name]; ok {
+			if err := s.fs.Set(name, v); err != nil {
+				return errors.Wrapf(err, "config file setting %s", name)
+			}
+			s.sources[name] = SourceFile
+		}
+	}
+	return nil
+}
+
+// Source of the current value of the setting name
+func (s *Set) Source(name string) Source {
+	return s.sources[name]
+}
+
+// Print all settings with their value and source, redacting secrets
+func (s *Set) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tENV\tVALUE\tSOURCE")
+	for _, name := range s.names {
+		value := s.fs.Lookup(name).Value.String()
+		if s.secrets[name] && value != "" {
+			value = redacted
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, s.EnvName(name), value, s.sources[name])
+	}
+	return tw.Flush()
+}
+
+// EnvName of the setting name, e.g. FEEDBACK_DB_PASSWORD for dbPassword with the prefix FEEDBACK_
+func (s *Set) EnvName(name string) string {
+	var b bytes.Buffer
+	b.WriteString(s.prefix)
+	for i, r := range name {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"debug", "TEST_DEBUG"},
+		{"dbPassword", "TEST_DB_PASSWORD"},
+		{"jaegerSamplerParam", "TEST_JAEGER_SAMPLER_PARAM"},
+	}
+	s, _ := newTestSet()
+	for _, tt := range tests {
+		if got := s.EnvName(tt.name); got != tt.want {
+			t.Errorf("EnvName(%q) = %q want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func newTestSet() (*Set, *flag.FlagSet) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	return New(fs, "TEST_"), fs
+}
+
+// writeFile with content to a temporary path, which is removed by the caller
+func writeFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func TestSet_Load(t *testing.T) {
+	s, fs := newTestSet()
+	host := s.String("dbHost", "127.0.0.1", "")
+	port := s.Int("dbPort", 5432, "")
+	password := s.Secret("dbPassword", "db", "")
+	timeout := s.Duration("requestTimeout", time.Second, "")
+	debug := s.Bool("debug", false, "")
+
+	path := writeFile(t, `
+db:
+  host: file
+  port: 5433
+  password: file
+requestTimeout: 3s
+`)
+	defer os.Remove(path)
+	if err := fs.Parse([]string{"-dbHost=flag"}); err != nil {
+		t.Fatal(err)
+	}
+	// DB_PORT as set by Kubernetes for a service called db is ignored without the prefix
+	vars := map[string]string{"TEST_DB_HOST": "env", "TEST_DB_PASSWORD": "env", "DB_PORT": "tcp://10.0.0.1:5432"}
+	if err := s.Load(path, env(vars)); err != nil {
+		t.Fatal(err)
+	}
+
+	if *host != "flag" || s.Source("dbHost") != SourceFlag {
+		t.Errorf("dbHost = %q from %s, want flag", *host, s.Source("dbHost"))
+	}
+	if *password != "env" || s.Source("dbPassword") != SourceEnv {
+		t.Errorf("dbPassword = %q from %s, want env", *password, s.Source("dbPassword"))
+	}
+	if *port != 5433 || s.Source("dbPort") != SourceFile {
+		t.Errorf("dbPort = %d from %s, want file", *port, s.Source("dbPort"))
+	}
+	if *timeout != 3*time.Second {
+		t.Errorf("requestTimeout = %v want %v", *timeout, 3*time.Second)
+	}
+	if *debug || s.Source("debug") != SourceDefault {
+		t.Errorf("debug = %v from %s, want default", *debug, s.Source("debug"))
+	}
+}
+
+func TestSet_LoadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+	}{
+		{"unknownSetting", "dbHost: db\ndbHots: db\n", nil},
+		{"invalidFileValue", "dbPort: high\n", nil},
+		{"invalidEnvValue", "", map[string]string{"TEST_DB_PORT": "high"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, fs := newTestSet()
+			s.String("dbHost", "", "")
+			s.Int("dbPort", 0, "")
+			if err := fs.Parse(nil); err != nil {
+				t.Fatal(err)
+			}
+			path := writeFile(t, tt.file)
+			defer os.Remove(path)
+			if err := s.Load(path, env(tt.env)); err == nil {
+				t.Fatal("Load() should fail")
+			}
+		})
+	}
+
+	path := writeFile(t, "")
+	os.Remove(path)
+	s, _ := newTestSet()
+	if err := s.Load(path, env(nil)); err == nil || !os.IsNotExist(errors.Cause(err)) {
+		t.Fatalf("Load() of missing file error = %v", err)
+	}
+}
+
+func TestSet_Print(t *testing.T) {
+	s, fs := newTestSet()
+	s.String("dbHost", "db", "")
+	s.Secret("dbPassword", "", "")
+	s.Secret("sentryDsn", "", "")
+	if err := fs.Parse([]string{"-dbPassword=hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Load("", env(nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "hunter2") {
+		t.Fatalf("Print() leaked secret:\n%s", out)
+	}
+	for _, want := range []string{"dbHost", "TEST_DB_HOST", "db", "dbPassword", redacted, "flag", "default"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Print() misses %q:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, redacted) != 1 {
+		t.Errorf("Print() should only redact set secrets:\n%s", out)
+	}
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// ParseFile reads settings from the YAML subset used for config files, which is not a full YAML parser.
+// Supported are
+//
+//   - "key: value" pairs, one per line
+//   - plain values, ending at the first " #"
+//   - double quoted values with Go escapes, e.g. key: "" for an empty value
+//   - single quoted values, escaping a quote by doubling it
+//   - comments on their own line or after a value
+//   - groups one level deep: a key without value followed by indented pairs
+//
+// Lists, nested groups, multi-line values, anchors and flow style ({...}) are not supported.
+// Grouped keys are joined in camel case, so
+//
+//	db:
+//	  host: db
+//	  password: "secret"
+//
+// sets dbHost and dbPassword.
+func ParseFile(r io.Reader) (map[string]string, error) {
+	settings := make(map[string]string)
+	var section string
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indented := line[0] == ' ' || line[0] == '\t'
+		if indented && section == "" {
+			return nil, errors.Errorf("line %d: unexpected indentation", n)
+		}
+
+		i := strings.Index(trimmed, ":")
+		if i < 1 {
+			return nil, errors.Errorf("line %d: expected key: value", n)
+		}
+		key := strings.TrimSpace(trimmed[:i])
+		raw := trimmed[i+1:]
+		if !indented {
+			section = ""
+			if isComment(raw) {
+				// a key without value starts a group
+				section = key
+				continue
+			}
+		} else {
+			key = section + upperFirst(key)
+		}
+
+		value, err := parseValue(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", n)
+		}
+		if _, ok := settings[key]; ok {
+			return nil, errors.Errorf("line %d: duplicate setting %q", n, key)
+		}
+		settings[key] = value
+	}
+	return settings, scanner.Err()
+}
+
+// parseValue unquotes v or strips a trailing comment from it
+func parseValue(v string) (string, error) {
+	v = strings.TrimSpace(v)
+	switch {
+	case strings.HasPrefix(v, `"`):
+		end := closingQuote(v, '"')
+		if end < 0 {
+			return "", errors.New("unterminated string")
+		}
+		if !isComment(v[end+1:]) {
+			return "", errors.New("unexpected text after string")
+		}
+		return strconv.Unquote(v[:end+1])
+	case strings.HasPrefix(v, "'"):
+		end := closingQuote(v, '\'')
+		if end < 0 {
+			return "", errors.New("unterminated string")
+		}
+		if !isComment(v[end+1:]) {
+			return "", errors.New("unexpected text after string")
+		}
+		return strings.Replace(v[1:end], "''", "'", -1), nil
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = v[:i]
+	}
+	return strings.TrimSpace(v), nil
+}
+
+// closingQuote returns the index of the quote ending the string v starts with, or -1.
+// Double quoted strings escape with a backslash, single quoted ones by doubling the quote.
+func closingQuote(v string, quote byte) int {
+	for i := 1; i < len(v); i++ {
+		switch {
+		case quote == '"' && v[i] == '\\':
+			i++
+		case v[i] != quote:
+		case quote == '\'' && i+1 < len(v) && v[i+1] == '\'':
+			i++
+		default:
+			return i
+		}
+	}
+	return -1
+}
+
+func isComment(rest string) bool {
+	rest = strings.TrimSpace(rest)
+	return rest == "" || strings.HasPrefix(rest, "#")
+}
+
+func upperFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[n:]
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"empty", "", map[string]string{}, false},
+		{
+			"flat",
+			"# service\nstore: memory\nrequestTimeout: 2s # per request\n",
+			map[string]string{"store": "memory", "requestTimeout": "2s"},
+			false,
+		},
+		{
+			"grouped",
+			"db:\n  host: db\n  port: 5432\n\tpassword: \"p#ss \\\"word\\\"\"\ndebug: true\n",
+			map[string]string{"dbHost": "db", "dbPort": "5432", "dbPassword": `p#ss "word"`, "debug": "true"},
+			false,
+		},
+		{"singleQuoted", "dbPassword: 'it''s' # comment\n", map[string]string{"dbPassword": "it's"}, false},
+		{"emptyGroup", "db:\nstore: memory\n", map[string]string{"store": "memory"}, false},
+		{"emptyValue", "dbPassword: \"\"\ndbHost: '' # none\n", map[string]string{"dbPassword": "", "dbHost": ""}, false},
+		{"quoteInComment", "dbPassword: \"a\" # \"x\"\n", map[string]string{"dbPassword": "a"}, false},
+		{"singleQuoteInComment", "dbPassword: 'a' # 'x'\n", map[string]string{"dbPassword": "a"}, false},
+		{"groupWithComment", "db: # connection\n  host: db\n", map[string]string{"dbHost": "db"}, false},
+		{"textAfterString", "dbPassword: \"a\" b\n", nil, true},
+		{"indentedWithoutGroup", "  host: db\n", nil, true},
+		{"missingColon", "store memory\n", nil, true},
+		{"unterminated", "dbPassword: \"secret\n", nil, true},
+		{"duplicate", "dbHost: a\ndb:\n  host: b\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFile(strings.NewReader(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseFile() = %v want %v", got, tt.want)
+			}
+		})
+	}
+}
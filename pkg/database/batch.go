@@ -0,0 +1,86 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// MaxBatchSize of AddBatch, keeping the statement within the 65535 parameters supported by PostgreSQL
+const MaxBatchSize = 1000
+
+// AddBatch inserts entries with a single multi-row statement.
+// The returned slice holds feedback.ErrDuplicateEntry for every entry that already existed or
+// appeared earlier in the batch and nil for inserted ones, err is set if the statement failed as a whole.
+func (c *Connection) AddBatch(ctx context.Context, entries []feedback.Entry) (errs []error, err error) {
+	defer func(start time.Time) { observe("add_batch", start, err) }(time.Now())
+	c.Debug("adding entries", zap.Int("entries", len(entries)))
+	if len(entries) > MaxBatchSize {
+		return nil, errors.Errorf("batch of %d entries exceeds %d", len(entries), MaxBatchSize)
+	}
+
+	errs = make([]error, len(entries))
+	keys := make(map[[2]string]bool, len(entries))
+	args := make([]interface{}, 0, 4*len(entries))
+	var b bytes.Buffer
+	b.WriteString("INSERT INTO entries(session_id, user_id, rating, comment) VALUES ")
+	for i, e := range entries {
+		// a statement can't insert the same key twice, so later entries are rejected right away
+		k := [2]string{e.SessionID, e.UserID}
+		if keys[k] {
+			errs[i] = feedback.ErrDuplicateEntry
+			continue
+		}
+		keys[k] = true
+		if len(args) > 0 {
+			b.WriteString(", ")
+		}
+		n := len(args)
+		b.WriteString("($" + strconv.Itoa(n+1) + ", $" + strconv.Itoa(n+2) + ", $" + strconv.Itoa(n+3) + ", $" + strconv.Itoa(n+4) + ")")
+		args = append(args, e.SessionID, e.UserID, e.Rating, e.Comment)
+	}
+	if len(args) == 0 {
+		return errs, nil
+	}
+	b.WriteString(" ON CONFLICT (session_id, user_id) DO NOTHING RETURNING session_id, user_id")
+	query := b.String()
+
+	// statements are not cached, as there is one per batch size
+	span := startSpan(ctx, "add_batch", query)
+	inserted, err := c.insertBatch(ctx, query, args)
+	finishSpan(span, int64(len(inserted)), err)
+	if err != nil {
+		c.Error("batch insert error", zap.Int("entries", len(entries)), zap.Error(err))
+		return nil, err
+	}
+
+	for i, e := range entries {
+		if errs[i] == nil && !inserted[[2]string{e.SessionID, e.UserID}] {
+			errs[i] = feedback.ErrDuplicateEntry
+		}
+	}
+	return errs, nil
+}
+
+// insertBatch runs query, returning the keys of the inserted rows
+func (c *Connection) insertBatch(ctx context.Context, query string, args []interface{}) (map[[2]string]bool, error) {
+	rows, err := c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	inserted := make(map[[2]string]bool)
+	for rows.Next() {
+		var k [2]string
+		if err := rows.Scan(&k[0], &k[1]); err != nil {
+			return nil, err
+		}
+		inserted[k] = true
+	}
+	return inserted, rows.Err()
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/playnet-public/libs/log"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestConnection_AddBatch(t *testing.T) {
+	entries := []feedback.Entry{
+		{SessionID: "s1", UserID: "u1", Rating: 1},
+		{SessionID: "s1", UserID: "u2", Rating: 2, Comment: "c"},
+		{SessionID: "s1", UserID: "u1", Rating: 3},
+		{SessionID: "s2", UserID: "u1", Rating: 4},
+	}
+	query := `INSERT INTO entries\(session_id, user_id, rating, comment\) VALUES \(\$1, \$2, \$3, \$4\), \(\$5, \$6, \$7, \$8\), \(\$9, \$10, \$11, \$12\) ON CONFLICT`
+	tests := []struct {
+		name     string
+		inserted [][2]string
+		err      error
+		wantErrs []error
+		wantErr  bool
+	}{
+		{
+			name:     "inserted",
+			inserted: [][2]string{{"s1", "u1"}, {"s1", "u2"}, {"s2", "u1"}},
+			wantErrs: []error{nil, nil, feedback.ErrDuplicateEntry, nil},
+		},
+		{
+			name:     "existing",
+			inserted: [][2]string{{"s1", "u2"}},
+			wantErrs: []error{feedback.ErrDuplicateEntry, nil, feedback.ErrDuplicateEntry, feedback.ErrDuplicateEntry},
+		},
+		{
+			name:    "failed",
+			err:     errors.New("connection reset"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			con := New(log.NewNop())
+			con.DB = db
+
+			exp := mock.ExpectQuery(query).WithArgs("s1", "u1", 1, "", "s1", "u2", 2, "c", "s2", "u1", 4, "")
+			if tt.err != nil {
+				exp.WillReturnError(tt.err)
+			} else {
+				rows := sqlmock.NewRows([]string{"session_id", "user_id"})
+				for _, k := range tt.inserted {
+					rows.AddRow(k[0], k[1])
+				}
+				exp.WillReturnRows(rows)
+			}
+
+			errs, err := con.AddBatch(context.Background(), entries)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AddBatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(errs, tt.wantErrs) {
+				t.Errorf("AddBatch() = %v want %v", errs, tt.wantErrs)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestConnection_AddBatchTooLarge(t *testing.T) {
+	con := New(log.NewNop())
+	if _, err := con.AddBatch(context.Background(), make([]feedback.Entry, MaxBatchSize+1)); err == nil {
+		t.Fatal("AddBatch() should reject batches exceeding MaxBatchSize")
+	}
+}
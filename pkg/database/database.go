@@ -1,8 +1,10 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/lib/pq"
 	"github.com/pkg/errors"
@@ -19,6 +21,19 @@ import (
 type Connection struct {
 	*log.Logger
 	*sql.DB
+
+	// AutoMigrate applies pending migrations when opening the connection
+	AutoMigrate bool
+
+	// ConnectTimeout for retrying the initial connection with exponential backoff, only trying once if zero
+	ConnectTimeout time.Duration
+	// MaxOpenConns, MaxIdleConns and ConnMaxLifetime configure the connection pool, see sql.DB.
+	// Zero values keep the defaults of database/sql.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+
+	stmts statements
 }
 
 // New database connection taking a sql connect string
@@ -32,20 +47,36 @@ func New(log *log.Logger) *Connection {
 // Open the db connection
 func (c *Connection) Open(con string) error {
 	c.Info("connecting db")
-	db, err := sql.Open("postgres", con)
+	db, err := c.open(driverName, con)
 	if err != nil {
-		return err
-	}
-	if err := db.Ping(); err != nil {
 		c.Error("open connection error", zap.Error(err))
 		return err
 	}
 	c.DB = db
+	if c.AutoMigrate {
+		n, err := c.MigrateUp()
+		if err != nil {
+			c.Error("migration error", zap.Error(err))
+			return err
+		}
+		c.Info("migrated db", zap.Int("applied", n))
+	}
 	return nil
 }
 
+// Close the database connection, to be called once the last request has been served
+func (c *Connection) Close() error {
+	if c.DB == nil {
+		return nil
+	}
+	c.Info("closing db")
+	c.stmts.close()
+	return c.DB.Close()
+}
+
 // Add feedback entry to DB
-func (c *Connection) Add(entry feedback.Entry) (err error) {
+func (c *Connection) Add(ctx context.Context, entry feedback.Entry) (err error) {
+	defer func(start time.Time) { observe("add", start, err) }(time.Now())
 	c.Debug("adding entry",
 		zap.String("session", entry.SessionID),
 		zap.String("user", entry.UserID),
@@ -55,7 +86,7 @@ func (c *Connection) Add(entry feedback.Entry) (err error) {
 	}()
 
 	query := "INSERT INTO entries(session_id, user_id, rating, comment) VALUES ($1, $2, $3, $4)"
-	statement, err := c.Prepare(query)
+	statement, err := c.prepare(ctx, query)
 	if err != nil {
 		c.Error("statement error",
 			zap.String("session", entry.SessionID),
@@ -65,7 +96,9 @@ func (c *Connection) Add(entry feedback.Entry) (err error) {
 		return err
 	}
 
-	_, err = statement.Exec(entry.SessionID, entry.UserID, entry.Rating, entry.Comment)
+	span := startSpan(ctx, "add", query)
+	res, err := statement.ExecContext(ctx, entry.SessionID, entry.UserID, entry.Rating, entry.Comment)
+	finishSpan(span, rowsAffected(res), err)
 	if err != nil {
 		c.Error("exec error",
 			zap.String("session", entry.SessionID),
@@ -78,8 +111,73 @@ func (c *Connection) Add(entry feedback.Entry) (err error) {
 	return nil
 }
 
+// Update rating and comment of an existing entry in the DB
+func (c *Connection) Update(ctx context.Context, entry feedback.Entry) (err error) {
+	defer func(start time.Time) { observe("update", start, err) }(time.Now())
+	c.Debug("updating entry",
+		zap.String("session", entry.SessionID),
+		zap.String("user", entry.UserID),
+	)
+
+	query := `UPDATE entries SET rating = $3, comment = $4, updated_at = now()
+	WHERE session_id = $1 AND user_id = $2`
+	statement, err := c.prepare(ctx, query)
+	if err != nil {
+		c.Error("statement error",
+			zap.String("session", entry.SessionID),
+			zap.String("user", entry.UserID),
+			zap.Error(err),
+		)
+		return err
+	}
+	span := startSpan(ctx, "update", query)
+	res, err := statement.ExecContext(ctx, entry.SessionID, entry.UserID, entry.Rating, entry.Comment)
+	finishSpan(span, rowsAffected(res), err)
+	if err != nil {
+		c.Error("exec error",
+			zap.String("session", entry.SessionID),
+			zap.String("user", entry.UserID),
+			zap.Error(err),
+		)
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n < 1 {
+		return feedback.ErrNotFound
+	}
+	return nil
+}
+
+// Get the entry of a user for a session from the database
+func (c *Connection) Get(ctx context.Context, sessionID, userID string) (entry feedback.Entry, err error) {
+	defer func(start time.Time) { observe("get", start, err) }(time.Now())
+	c.Debug("reading entry",
+		zap.String("session", sessionID),
+		zap.String("user", userID),
+	)
+	query := `SELECT id, session_id, user_id, rating, comment, created_at, updated_at FROM entries
+	WHERE session_id = $1 AND user_id = $2`
+	entries, err := c.getEntries(ctx, "get", query, sessionID, userID)
+	if err != nil {
+		c.Error("get entry failed",
+			zap.String("session", sessionID),
+			zap.String("user", userID),
+			zap.Error(err),
+		)
+		return feedback.Entry{}, err
+	}
+	if len(entries) < 1 {
+		return feedback.Entry{}, feedback.ErrNotFound
+	}
+	return entries[0], nil
+}
+
 // GetLatest n entries from the database
-func (c *Connection) GetLatest(n uint) (entries []feedback.Entry, err error) {
+func (c *Connection) GetLatest(ctx context.Context, n uint) (entries []feedback.Entry, err error) {
+	defer func(start time.Time) { observe("get_latest", start, err) }(time.Now())
 	c.Debug("reading entries",
 		zap.Uint("limit", n),
 	)
@@ -88,9 +186,9 @@ func (c *Connection) GetLatest(n uint) (entries []feedback.Entry, err error) {
 		zap.Int("entries", len(entries)),
 	)
 
-	query := `SELECT id, session_id, user_id, rating, comment FROM entries
+	query := `SELECT id, session_id, user_id, rating, comment, created_at, updated_at FROM entries
 	ORDER BY id DESC LIMIT $1`
-	entries, err = c.getEntries(query, n)
+	entries, err = c.getEntries(ctx, "get_latest", query, n)
 	if err != nil {
 		c.Error("get entries failed",
 			zap.Uint("limit", n),
@@ -102,7 +200,8 @@ func (c *Connection) GetLatest(n uint) (entries []feedback.Entry, err error) {
 }
 
 // GetLatestFiltered n entries by rating from the database
-func (c *Connection) GetLatestFiltered(n uint, filter int) (entries []feedback.Entry, err error) {
+func (c *Connection) GetLatestFiltered(ctx context.Context, n uint, filter int) (entries []feedback.Entry, err error) {
+	defer func(start time.Time) { observe("get_latest_filtered", start, err) }(time.Now())
 	c.Debug("reading entries",
 		zap.Uint("limit", n),
 		zap.Int("filter", filter),
@@ -113,9 +212,9 @@ func (c *Connection) GetLatestFiltered(n uint, filter int) (entries []feedback.E
 		zap.Int("entries", len(entries)),
 	)
 
-	query := `SELECT id, session_id, user_id, rating, comment FROM entries WHERE rating = $2
+	query := `SELECT id, session_id, user_id, rating, comment, created_at, updated_at FROM entries WHERE rating = $2
 	ORDER BY id DESC LIMIT $1`
-	entries, err = c.getEntries(query, n, filter)
+	entries, err = c.getEntries(ctx, "get_latest_filtered", query, n, filter)
 	if err != nil {
 		c.Error("get entries failed",
 			zap.Uint("limit", n),
@@ -127,19 +226,245 @@ func (c *Connection) GetLatestFiltered(n uint, filter int) (entries []feedback.E
 	return entries, err
 }
 
-func (c *Connection) getEntries(query string, args ...interface{}) ([]feedback.Entry, error) {
-	statement, err := c.Prepare(query)
+// GetLatestInRange n entries created within r from the database
+func (c *Connection) GetLatestInRange(ctx context.Context, n uint, r feedback.Range) (entries []feedback.Entry, err error) {
+	defer func(start time.Time) { observe("get_latest_in_range", start, err) }(time.Now())
+	c.Debug("reading entries",
+		zap.Uint("limit", n),
+		zap.Time("since", r.Since),
+		zap.Time("until", r.Until),
+	)
+	defer c.Debug("finished reading entries",
+		zap.Uint("limit", n),
+		zap.Int("entries", len(entries)),
+	)
+	query := `SELECT id, session_id, user_id, rating, comment, created_at, updated_at FROM entries
+	WHERE ($2::timestamptz IS NULL OR created_at >= $2) AND ($3::timestamptz IS NULL OR created_at < $3)
+	ORDER BY id DESC LIMIT $1`
+	entries, err = c.getEntries(ctx, "get_latest_in_range", query, n, nullTime(r.Since), nullTime(r.Until))
+	if err != nil {
+		c.Error("get entries failed",
+			zap.Uint("limit", n),
+			zap.Error(err),
+		)
+	}
+	return entries, err
+}
+
+// GetLatestFilteredInRange n entries by rating created within r from the database
+func (c *Connection) GetLatestFilteredInRange(ctx context.Context, n uint, filter int, r feedback.Range) (entries []feedback.Entry, err error) {
+	defer func(start time.Time) { observe("get_latest_filtered_in_range", start, err) }(time.Now())
+	c.Debug("reading entries",
+		zap.Uint("limit", n),
+		zap.Int("filter", filter),
+		zap.Time("since", r.Since),
+		zap.Time("until", r.Until),
+	)
+	defer c.Debug("finished reading entries",
+		zap.Uint("limit", n),
+		zap.Int("filter", filter),
+		zap.Int("entries", len(entries)),
+	)
+	query := `SELECT id, session_id, user_id, rating, comment, created_at, updated_at FROM entries WHERE rating = $2
+	AND ($3::timestamptz IS NULL OR created_at >= $3) AND ($4::timestamptz IS NULL OR created_at < $4)
+	ORDER BY id DESC LIMIT $1`
+	entries, err = c.getEntries(ctx, "get_latest_filtered_in_range", query, n, filter, nullTime(r.Since), nullTime(r.Until))
+	if err != nil {
+		c.Error("get entries failed",
+			zap.Uint("limit", n),
+			zap.Int("filter", filter),
+			zap.Error(err),
+		)
+	}
+	return entries, err
+}
+
+// GetLatestBefore n entries created within r and older than the entry with id before from the database
+func (c *Connection) GetLatestBefore(ctx context.Context, n uint, before string, r feedback.Range) (entries []feedback.Entry, err error) {
+	defer func(start time.Time) { observe("get_latest_before", start, err) }(time.Now())
+	c.Debug("reading entries",
+		zap.Uint("limit", n),
+		zap.String("before", before),
+	)
+	defer c.Debug("finished reading entries",
+		zap.Uint("limit", n),
+		zap.String("before", before),
+		zap.Int("entries", len(entries)),
+	)
+	id, err := nullID(before)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT id, session_id, user_id, rating, comment, created_at, updated_at FROM entries
+	WHERE ($2::integer IS NULL OR id < $2)
+	AND ($3::timestamptz IS NULL OR created_at >= $3) AND ($4::timestamptz IS NULL OR created_at < $4)
+	ORDER BY id DESC LIMIT $1`
+	entries, err = c.getEntries(ctx, "get_latest_before", query, n, id, nullTime(r.Since), nullTime(r.Until))
+	if err != nil {
+		c.Error("get entries failed",
+			zap.Uint("limit", n),
+			zap.String("before", before),
+			zap.Error(err),
+		)
+	}
+	return entries, err
+}
+
+// GetLatestFilteredBefore n entries by rating created within r and older than the entry with id before from the database
+func (c *Connection) GetLatestFilteredBefore(ctx context.Context, n uint, filter int, before string, r feedback.Range) (entries []feedback.Entry, err error) {
+	defer func(start time.Time) { observe("get_latest_filtered_before", start, err) }(time.Now())
+	c.Debug("reading entries",
+		zap.Uint("limit", n),
+		zap.Int("filter", filter),
+		zap.String("before", before),
+	)
+	defer c.Debug("finished reading entries",
+		zap.Uint("limit", n),
+		zap.Int("filter", filter),
+		zap.String("before", before),
+		zap.Int("entries", len(entries)),
+	)
+	id, err := nullID(before)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT id, session_id, user_id, rating, comment, created_at, updated_at FROM entries WHERE rating = $2
+	AND ($3::integer IS NULL OR id < $3)
+	AND ($4::timestamptz IS NULL OR created_at >= $4) AND ($5::timestamptz IS NULL OR created_at < $5)
+	ORDER BY id DESC LIMIT $1`
+	entries, err = c.getEntries(ctx, "get_latest_filtered_before", query, n, filter, id, nullTime(r.Since), nullTime(r.Until))
+	if err != nil {
+		c.Error("get entries failed",
+			zap.Uint("limit", n),
+			zap.Int("filter", filter),
+			zap.String("before", before),
+			zap.Error(err),
+		)
+	}
+	return entries, err
+}
+
+// GetBySession returns all entries of a session from the database
+func (c *Connection) GetBySession(ctx context.Context, sessionID string) (entries []feedback.Entry, err error) {
+	defer func(start time.Time) { observe("get_by_session", start, err) }(time.Now())
+	c.Debug("reading session entries",
+		zap.String("session", sessionID),
+	)
+	defer c.Debug("finished reading session entries",
+		zap.String("session", sessionID),
+		zap.Int("entries", len(entries)),
+	)
+	query := `SELECT id, session_id, user_id, rating, comment, created_at, updated_at FROM entries WHERE session_id = $1
+	ORDER BY id DESC`
+	entries, err = c.getEntries(ctx, "get_by_session", query, sessionID)
+	if err != nil {
+		c.Error("get entries failed",
+			zap.String("session", sessionID),
+			zap.Error(err),
+		)
+	}
+	return entries, err
+}
+
+// GetStats aggregates all entries created within r in the database
+func (c *Connection) GetStats(ctx context.Context, r feedback.Range, bySession bool) (stats feedback.Stats, err error) {
+	defer func(start time.Time) { observe("get_stats", start, err) }(time.Now())
+	c.Debug("reading stats",
+		zap.Time("since", r.Since),
+		zap.Time("until", r.Until),
+		zap.Bool("bySession", bySession),
+	)
+	defer func() {
+		if err != nil {
+			c.Error("get stats failed", zap.Error(err))
+		}
+	}()
+
+	query := `SELECT '', rating, count(*) FROM entries
+	WHERE ($1::timestamptz IS NULL OR created_at >= $1) AND ($2::timestamptz IS NULL OR created_at < $2)
+	GROUP BY rating`
+	if bySession {
+		query = `SELECT session_id, rating, count(*) FROM entries
+	WHERE ($1::timestamptz IS NULL OR created_at >= $1) AND ($2::timestamptz IS NULL OR created_at < $2)
+	GROUP BY session_id, rating`
+	}
+	var groups int64
+	span := startSpan(ctx, "get_stats", query)
+	defer func() { finishSpan(span, groups, err) }()
+
+	statement, err := c.prepare(ctx, query)
+	if err != nil {
+		return stats, errors.Wrap(err, "statement error")
+	}
+	rows, err := statement.QueryContext(ctx, nullTime(r.Since), nullTime(r.Until))
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+
+	total := make(map[int8]int)
+	var sessions map[string]map[int8]int
+	if bySession {
+		sessions = make(map[string]map[int8]int)
+	}
+	for rows.Next() {
+		var (
+			session string
+			rating  int8
+			count   int
+		)
+		if err := rows.Scan(&session, &rating, &count); err != nil {
+			return stats, errors.Wrap(err, "row scan error")
+		}
+		groups++
+		total[rating] += count
+		if bySession {
+			if sessions[session] == nil {
+				sessions[session] = make(map[int8]int)
+			}
+			sessions[session][rating] += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	return feedback.NewStats(total, sessions), nil
+}
+
+func nullID(id string) (interface{}, error) {
+	if id == "" {
+		return nil, nil
+	}
+	i, err := strconv.ParseInt(id, 10, 32)
+	if err != nil {
+		return nil, feedback.ErrInvalidCursor
+	}
+	return i, nil
+}
+
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+func (c *Connection) getEntries(ctx context.Context, op, query string, args ...interface{}) (entries []feedback.Entry, err error) {
+	span := startSpan(ctx, op, query)
+	defer func() { finishSpan(span, int64(len(entries)), err) }()
+
+	statement, err := c.prepare(ctx, query)
 	if err != nil {
 		return nil, errors.Wrap(err, "statement error")
 	}
-	rows, err := statement.Query(args...)
+	rows, err := statement.QueryContext(ctx, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	entry := feedback.Entry{}
-	var entries []feedback.Entry
 	var count int8
 
 	for rows.Next() {
@@ -149,6 +474,8 @@ func (c *Connection) getEntries(query string, args ...interface{}) ([]feedback.E
 			&entry.UserID,
 			&entry.Rating,
 			&entry.Comment,
+			&entry.CreatedAt,
+			&entry.UpdatedAt,
 		)
 		if err != nil {
 			return nil, errors.Wrap(err, "row scan error")
@@ -156,7 +483,10 @@ func (c *Connection) getEntries(query string, args ...interface{}) ([]feedback.E
 		entries = append(entries, entry)
 		count++
 	}
-	fmt.Println(entries)
+	if err := rows.Err(); err != nil {
+		// e.g. the request was cancelled while reading, the entries are incomplete
+		return nil, errors.Wrap(err, "rows error")
+	}
 	return entries, nil
 }
 
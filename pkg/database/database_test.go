@@ -1,11 +1,17 @@
 package database
 
 import (
+	"context"
+	"database/sql/driver"
+	"os"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/lib/pq"
 
 	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback/feedbacktest"
 	"github.com/playnet-public/libs/log"
 	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
@@ -18,6 +24,27 @@ func TestConnection_Open(t *testing.T) {
 	}
 }
 
+// TestConnection_Repository runs the repository conformance suite against a real database
+// if TEST_DB_DSN is set, e.g. TEST_DB_DSN="host=127.0.0.1 user=db password=db dbname=db sslmode=disable"
+// The schema is migrated automatically.
+func TestConnection_Repository(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN not set")
+	}
+	feedbacktest.TestRepository(t, func(t *testing.T) feedback.Repository {
+		con := New(log.NewNop())
+		con.AutoMigrate = true
+		if err := con.Open(dsn); err != nil {
+			t.Fatal("open error", err)
+		}
+		if _, err := con.Exec("TRUNCATE entries RESTART IDENTITY"); err != nil {
+			t.Fatal("truncate error", err)
+		}
+		return con
+	})
+}
+
 func TestConnection_Add(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -84,7 +111,7 @@ func TestConnection_Add(t *testing.T) {
 			}
 			mock.MatchExpectationsInOrder(false)
 
-			err := con.Add(tt.input)
+			err := con.Add(context.Background(), tt.input)
 			if (err == nil) == tt.err {
 				t.Fatalf("Add() == %v want %v", err, tt.err)
 			}
@@ -165,11 +192,11 @@ func TestConnection_GetLatest(t *testing.T) {
 
 			mock.MatchExpectationsInOrder(false)
 
-			query := `SELECT id, session_id, user_id, rating, comment FROM entries
+			query := `SELECT id, session_id, user_id, rating, comment, created_at, updated_at FROM entries
 			ORDER BY id DESC LIMIT (.+)`
-			rows := sqlmock.NewRows([]string{"id", "session_id", "user_id", "rating", "comment"})
+			rows := sqlmock.NewRows([]string{"id", "session_id", "user_id", "rating", "comment", "created_at", "updated_at"})
 			for i, e := range tt.result {
-				rows = rows.AddRow(i+1, e.SessionID, e.UserID, e.Rating, e.Comment)
+				rows = rows.AddRow(i+1, e.SessionID, e.UserID, e.Rating, e.Comment, e.CreatedAt, e.UpdatedAt)
 			}
 			if tt.expectedPrepare {
 				mock.ExpectPrepare(query)
@@ -178,7 +205,7 @@ func TestConnection_GetLatest(t *testing.T) {
 				mock.ExpectQuery(query).WithArgs(1).WillReturnRows(rows)
 			}
 
-			entries, err := con.GetLatest(tt.input)
+			entries, err := con.GetLatest(context.Background(), tt.input)
 			if (err == nil) == tt.err {
 				t.Fatalf("Add() == %v want %v", err, tt.err)
 			}
@@ -266,11 +293,11 @@ func TestConnection_GetLatestFiltered(t *testing.T) {
 
 			mock.MatchExpectationsInOrder(false)
 
-			query := `SELECT id, session_id, user_id, rating, comment FROM entries WHERE rating = (.+)
+			query := `SELECT id, session_id, user_id, rating, comment, created_at, updated_at FROM entries WHERE rating = (.+)
 			ORDER BY id DESC LIMIT (.+)`
-			rows := sqlmock.NewRows([]string{"id", "session_id", "user_id", "rating", "comment"})
+			rows := sqlmock.NewRows([]string{"id", "session_id", "user_id", "rating", "comment", "created_at", "updated_at"})
 			for i, e := range tt.result {
-				rows = rows.AddRow(i+1, e.SessionID, e.UserID, e.Rating, e.Comment)
+				rows = rows.AddRow(i+1, e.SessionID, e.UserID, e.Rating, e.Comment, e.CreatedAt, e.UpdatedAt)
 			}
 			if tt.expectedPrepare {
 				mock.ExpectPrepare(query)
@@ -279,7 +306,7 @@ func TestConnection_GetLatestFiltered(t *testing.T) {
 				mock.ExpectQuery(query).WithArgs(1, 2).WillReturnRows(rows)
 			}
 
-			entries, err := con.GetLatestFiltered(tt.limit, tt.filter)
+			entries, err := con.GetLatestFiltered(context.Background(), tt.limit, tt.filter)
 			if (err == nil) == tt.err {
 				t.Fatalf("Add() == %v want %v", err, tt.err)
 			}
@@ -294,6 +321,313 @@ func TestConnection_GetLatestFiltered(t *testing.T) {
 	}
 }
 
+func TestConnection_GetLatestFilteredInRange(t *testing.T) {
+	since := time.Date(2018, 3, 17, 20, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		filter int
+		r      feedback.Range
+		args   []driver.Value
+	}{
+		{
+			"unbounded",
+			0,
+			feedback.Range{},
+			[]driver.Value{1, nil, nil},
+		},
+		{
+			"since",
+			0,
+			feedback.Range{Since: since},
+			[]driver.Value{1, since, nil},
+		},
+		{
+			"filteredUntil",
+			2,
+			feedback.Range{Until: since},
+			[]driver.Value{1, 2, nil, since},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer db.Close()
+
+			con := New(log.NewNop())
+			con.DB = db
+
+			query := `SELECT id, session_id, user_id, rating, comment, created_at, updated_at FROM entries (.+) ORDER BY id DESC LIMIT (.+)`
+			rows := sqlmock.NewRows([]string{"id", "session_id", "user_id", "rating", "comment", "created_at", "updated_at"}).
+				AddRow(1, "abc123", "123abc", 2, "test", since, since)
+			mock.ExpectPrepare(query)
+			mock.ExpectQuery(query).WithArgs(tt.args...).WillReturnRows(rows)
+
+			var entries []feedback.Entry
+			if tt.filter > 0 {
+				entries, err = con.GetLatestFilteredInRange(context.Background(), 1, tt.filter, tt.r)
+			} else {
+				entries, err = con.GetLatestInRange(context.Background(), 1, tt.r)
+			}
+			if err != nil {
+				t.Fatal("GetLatestInRange() error", err)
+			}
+			if len(entries) != 1 || !entries[0].CreatedAt.Equal(since) {
+				t.Fatalf("GetLatestInRange() = %v", entries)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal("expectations not met for query", err)
+			}
+		})
+	}
+}
+
+func TestConnection_GetLatestFilteredBefore(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter int
+		before string
+		args   []driver.Value
+		err    error
+	}{
+		{
+			"first",
+			0,
+			"",
+			[]driver.Value{1, nil, nil, nil},
+			nil,
+		},
+		{
+			"before",
+			0,
+			"42",
+			[]driver.Value{1, 42, nil, nil},
+			nil,
+		},
+		{
+			"filteredBefore",
+			2,
+			"42",
+			[]driver.Value{1, 2, 42, nil, nil},
+			nil,
+		},
+		{
+			"invalidCursor",
+			0,
+			"abc",
+			nil,
+			feedback.ErrInvalidCursor,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer db.Close()
+
+			con := New(log.NewNop())
+			con.DB = db
+
+			query := `SELECT id, session_id, user_id, rating, comment, created_at, updated_at FROM entries (.+) ORDER BY id DESC LIMIT (.+)`
+			rows := sqlmock.NewRows([]string{"id", "session_id", "user_id", "rating", "comment", "created_at", "updated_at"}).
+				AddRow(41, "abc123", "123abc", 2, "test", time.Now(), time.Now())
+			if tt.args != nil {
+				mock.ExpectPrepare(query)
+				mock.ExpectQuery(query).WithArgs(tt.args...).WillReturnRows(rows)
+			}
+
+			if tt.filter > 0 {
+				_, err = con.GetLatestFilteredBefore(context.Background(), 1, tt.filter, tt.before, feedback.Range{})
+			} else {
+				_, err = con.GetLatestBefore(context.Background(), 1, tt.before, feedback.Range{})
+			}
+			if err != tt.err {
+				t.Fatalf("GetLatestBefore() = %v want %v", err, tt.err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal("expectations not met for query", err)
+			}
+		})
+	}
+}
+
+func TestConnection_GetBySession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	con := New(log.NewNop())
+	con.DB = db
+
+	query := `SELECT id, session_id, user_id, rating, comment, created_at, updated_at FROM entries WHERE session_id = (.+)
+	ORDER BY id DESC`
+	rows := sqlmock.NewRows([]string{"id", "session_id", "user_id", "rating", "comment", "created_at", "updated_at"}).
+		AddRow(2, "abc123", "2", 2, "test", time.Now(), time.Now()).
+		AddRow(1, "abc123", "1", 5, "test", time.Now(), time.Now())
+	mock.ExpectPrepare(query)
+	mock.ExpectQuery(query).WithArgs("abc123").WillReturnRows(rows)
+
+	entries, err := con.GetBySession(context.Background(), "abc123")
+	if err != nil {
+		t.Fatal("GetBySession() error", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("GetBySession() returned %d entries want 2", len(entries))
+	}
+
+	// failing to read all rows must not return the ones read so far
+	rows = sqlmock.NewRows([]string{"id", "session_id", "user_id", "rating", "comment", "created_at", "updated_at"}).
+		AddRow(2, "abc123", "2", 2, "test", time.Now(), time.Now()).
+		AddRow(1, "abc123", "1", 5, "test", time.Now(), time.Now()).
+		RowError(1, errConnectionReset)
+	mock.ExpectQuery(query).WithArgs("abc123").WillReturnRows(rows)
+	if entries, err := con.GetBySession(context.Background(), "abc123"); err == nil {
+		t.Fatalf("GetBySession() = %d entries without error", len(entries))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal("expectations not met for query", err)
+	}
+}
+
+func TestConnection_Update(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		err      error
+	}{
+		{"updated", 1, nil},
+		{"notFound", 0, feedback.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer db.Close()
+
+			con := New(log.NewNop())
+			con.DB = db
+
+			mock.ExpectPrepare("UPDATE entries SET (.+) WHERE (.+)")
+			mock.ExpectExec("UPDATE entries SET (.+) WHERE (.+)").
+				WithArgs("abc123", "123abc", 5, "fixed").
+				WillReturnResult(sqlmock.NewResult(0, tt.affected))
+
+			err = con.Update(context.Background(), feedback.Entry{SessionID: "abc123", UserID: "123abc", Rating: 5, Comment: "fixed"})
+			if err != tt.err {
+				t.Fatalf("Update() = %v want %v", err, tt.err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal("expectations not met for update", err)
+			}
+		})
+	}
+}
+
+func TestConnection_Get(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	con := New(log.NewNop())
+	con.DB = db
+
+	query := `SELECT (.+) FROM entries WHERE session_id = (.+) AND user_id = (.+)`
+	mock.ExpectPrepare(query)
+	mock.ExpectQuery(query).WithArgs("abc123", "123abc").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "session_id", "user_id", "rating", "comment", "created_at", "updated_at"}).
+			AddRow(1, "abc123", "123abc", 2, "test", time.Now(), time.Now()),
+	)
+	// the statement is prepared only once
+	mock.ExpectQuery(query).WithArgs("abc123", "unknown").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "session_id", "user_id", "rating", "comment", "created_at", "updated_at"}),
+	)
+
+	entry, err := con.Get(context.Background(), "abc123", "123abc")
+	if err != nil {
+		t.Fatal("Get() error", err)
+	}
+	if entry.UserID != "123abc" || entry.Rating != 2 {
+		t.Fatalf("Get() = %v", entry)
+	}
+	if _, err := con.Get(context.Background(), "abc123", "unknown"); err != feedback.ErrNotFound {
+		t.Fatalf("Get() = %v want %v", err, feedback.ErrNotFound)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal("expectations not met for query", err)
+	}
+}
+
+func TestConnection_GetStats(t *testing.T) {
+	tests := []struct {
+		name      string
+		bySession bool
+		rows      *sqlmock.Rows
+		want      feedback.Stats
+	}{
+		{
+			"total",
+			false,
+			sqlmock.NewRows([]string{"session_id", "rating", "count"}).
+				AddRow("", 1, 1).
+				AddRow("", 5, 3),
+			feedback.NewStats(map[int8]int{1: 1, 5: 3}, nil),
+		},
+		{
+			"bySession",
+			true,
+			sqlmock.NewRows([]string{"session_id", "rating", "count"}).
+				AddRow("a", 1, 1).
+				AddRow("a", 5, 1).
+				AddRow("b", 5, 2),
+			feedback.NewStats(map[int8]int{1: 1, 5: 3}, map[string]map[int8]int{
+				"a": {1: 1, 5: 1},
+				"b": {5: 2},
+			}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer db.Close()
+
+			con := New(log.NewNop())
+			con.DB = db
+
+			query := `SELECT (.+), rating, count\(\*\) FROM entries (.+) GROUP BY (.+)`
+			mock.ExpectPrepare(query)
+			mock.ExpectQuery(query).WithArgs(nil, nil).WillReturnRows(tt.rows)
+
+			got, err := con.GetStats(context.Background(), feedback.Range{}, tt.bySession)
+			if err != nil {
+				t.Fatal("GetStats() error", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("GetStats() = %v want %v", got, tt.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal("expectations not met for query", err)
+			}
+		})
+	}
+}
+
 func TestHandleError(t *testing.T) {
 	errs := []struct {
 		in  error
@@ -315,3 +649,29 @@ func TestHandleError(t *testing.T) {
 	}
 
 }
+
+func TestConnection_AddTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	con := New(log.NewNop())
+	con.DB = db
+
+	query := "INSERT INTO entries(.+) VALUES (.+)"
+	mock.ExpectPrepare(query)
+	mock.ExpectExec(query).WillDelayFor(time.Second).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = con.Add(ctx, feedback.Entry{SessionID: "abc123", UserID: "123abc", Rating: 1})
+	if err == nil {
+		t.Fatal("Add() should fail once the context is done")
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("Add() kept running for %v after the context was done", time.Since(start))
+	}
+}
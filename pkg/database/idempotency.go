@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"go.uber.org/zap"
+)
+
+// Reserve key for a request with the fingerprint hash, returning the record of the first request if it is taken.
+// Reservations in progress for longer than lease are taken over.
+func (c *Connection) Reserve(ctx context.Context, userID, key, hash string, lease time.Duration) (rec feedback.IdempotencyRecord, ok bool, err error) {
+	defer func(start time.Time) { observe("idempotency_reserve", start, err) }(time.Now())
+
+	insert := `INSERT INTO idempotency_keys (user_id, key, request_hash) VALUES ($1, $2, $3)
+	ON CONFLICT (user_id, key) DO UPDATE SET request_hash = EXCLUDED.request_hash, reserved_at = now()
+	WHERE idempotency_keys.status IS NULL AND $4::float8 > 0
+	AND idempotency_keys.reserved_at < now() - $4::float8 * interval '1 second'`
+	query := `SELECT request_hash, status, content_type, body FROM idempotency_keys WHERE user_id = $1 AND key = $2`
+	// the first request might release the key between both statements
+	for attempt := 0; attempt < 2; attempt++ {
+		res, err := c.exec(ctx, "idempotency_reserve", insert, userID, key, hash, lease.Seconds())
+		if err != nil {
+			c.Error("reserving idempotency key failed", zap.String("key", key), zap.Error(err))
+			return rec, false, err
+		}
+		if rowsAffected(res) == 1 {
+			return rec, true, nil
+		}
+
+		statement, err := c.prepare(ctx, query)
+		if err != nil {
+			c.Error("statement error", zap.String("key", key), zap.Error(err))
+			return rec, false, err
+		}
+		span := startSpan(ctx, "idempotency_get", query)
+		var (
+			status      sql.NullInt64
+			contentType sql.NullString
+			body        []byte
+		)
+		err = statement.QueryRowContext(ctx, userID, key).Scan(&rec.Hash, &status, &contentType, &body)
+		finishSpan(span, 1, err)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			c.Error("reading idempotency key failed", zap.String("key", key), zap.Error(err))
+			return rec, false, err
+		}
+		if status.Valid {
+			rec.Response = &feedback.Response{Status: int(status.Int64), ContentType: contentType.String, Body: body}
+		}
+		return rec, false, nil
+	}
+	return rec, false, feedback.ErrIdempotencyKeyInUse
+}
+
+// Complete the reservation of key, storing the response to replay
+func (c *Connection) Complete(ctx context.Context, userID, key string, resp feedback.Response) (err error) {
+	defer func(start time.Time) { observe("idempotency_complete", start, err) }(time.Now())
+
+	query := `UPDATE idempotency_keys SET status = $3, content_type = $4, body = $5 WHERE user_id = $1 AND key = $2`
+	_, err = c.exec(ctx, "idempotency_complete", query, userID, key, resp.Status, resp.ContentType, resp.Body)
+	return err
+}
+
+// Release the reservation of key unless it has been completed
+func (c *Connection) Release(ctx context.Context, userID, key string) (err error) {
+	defer func(start time.Time) { observe("idempotency_release", start, err) }(time.Now())
+
+	query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND status IS NULL`
+	_, err = c.exec(ctx, "idempotency_release", query, userID, key)
+	return err
+}
+
+// ExpireIdempotencyKeys deletes idempotency keys older than maxAge every maxAge until ctx is done.
+// Retries after that are processed as new requests.
+func (c *Connection) ExpireIdempotencyKeys(ctx context.Context, maxAge time.Duration) {
+	t := time.NewTicker(maxAge)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		res, err := c.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < now() - $1 * interval '1 second'`, maxAge.Seconds())
+		if err != nil {
+			if ctx.Err() == nil {
+				c.Error("expiring idempotency keys failed", zap.Error(err))
+			}
+			continue
+		}
+		c.Debug("expired idempotency keys", zap.Int64("keys", rowsAffected(res)))
+	}
+}
+
+// exec a prepared statement, tracing it as op
+func (c *Connection) exec(ctx context.Context, op, query string, args ...interface{}) (sql.Result, error) {
+	statement, err := c.prepare(ctx, query)
+	if err != nil {
+		c.Error("statement error", zap.String("op", op), zap.Error(err))
+		return nil, err
+	}
+	span := startSpan(ctx, op, query)
+	res, err := statement.ExecContext(ctx, args...)
+	finishSpan(span, rowsAffected(res), err)
+	return res, err
+}
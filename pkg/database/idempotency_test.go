@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback/feedbacktest"
+	"github.com/playnet-public/libs/log"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// TestConnection_IdempotencyStore runs the idempotency conformance suite against a real database if TEST_DB_DSN is set
+func TestConnection_IdempotencyStore(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN not set")
+	}
+	feedbacktest.TestIdempotencyStore(t, func(t *testing.T) feedback.IdempotencyStore {
+		con := New(log.NewNop())
+		con.AutoMigrate = true
+		if err := con.Open(dsn); err != nil {
+			t.Fatal("open error", err)
+		}
+		if _, err := con.Exec("TRUNCATE idempotency_keys"); err != nil {
+			t.Fatal("truncate error", err)
+		}
+		return con
+	})
+}
+
+func TestConnection_Reserve(t *testing.T) {
+	insert := `INSERT INTO idempotency_keys (.+) ON CONFLICT \(user_id, key\) DO UPDATE (.+) WHERE (.+)status IS NULL`
+	query := `SELECT request_hash, status, content_type, body FROM idempotency_keys WHERE (.+)`
+	columns := []string{"request_hash", "status", "content_type", "body"}
+	tests := []struct {
+		name    string
+		expect  func(mock sqlmock.Sqlmock)
+		want    feedback.IdempotencyRecord
+		wantOK  bool
+		wantErr error
+	}{
+		{
+			name: "reserved",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectPrepare(insert)
+				mock.ExpectExec(insert).WithArgs("u1", "k1", "h1", 10.0).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantOK: true,
+		},
+		{
+			name: "inProgress",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectPrepare(insert)
+				mock.ExpectExec(insert).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectPrepare(query)
+				mock.ExpectQuery(query).WithArgs("u1", "k1").WillReturnRows(sqlmock.NewRows(columns).AddRow("h0", nil, nil, nil))
+			},
+			want: feedback.IdempotencyRecord{Hash: "h0"},
+		},
+		{
+			name: "completed",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectPrepare(insert)
+				mock.ExpectExec(insert).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectPrepare(query)
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows(columns).AddRow("h1", 409, "application/json", []byte(`{"code":"duplicate_entry"}`)))
+			},
+			want: feedback.IdempotencyRecord{Hash: "h1", Response: &feedback.Response{
+				Status: 409, ContentType: "application/json", Body: []byte(`{"code":"duplicate_entry"}`),
+			}},
+		},
+		{
+			name: "releasedMeanwhile",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectPrepare(insert)
+				mock.ExpectExec(insert).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectPrepare(query)
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows(columns))
+				mock.ExpectExec(insert).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantOK: true,
+		},
+		{
+			name: "error",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectPrepare(insert)
+				mock.ExpectExec(insert).WillReturnError(errConnectionReset)
+			},
+			wantErr: errConnectionReset,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			con := New(log.NewNop())
+			con.DB = db
+			tt.expect(mock)
+
+			rec, ok, err := con.Reserve(context.Background(), "u1", "k1", "h1", 10*time.Second)
+			if err != tt.wantErr {
+				t.Fatalf("Reserve() error = %v want %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOK || !reflect.DeepEqual(rec, tt.want) {
+				t.Errorf("Reserve() = %+v, %v want %+v, %v", rec, ok, tt.want, tt.wantOK)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+var errConnectionReset = errors.New("connection reset")
+
+func TestConnection_CompleteRelease(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	con := New(log.NewNop())
+	con.DB = db
+
+	update := `UPDATE idempotency_keys SET status = (.+) WHERE user_id = (.+) AND key = (.+)`
+	mock.ExpectPrepare(update)
+	mock.ExpectExec(update).WithArgs("u1", "k1", 200, "application/json", []byte("{}")).WillReturnResult(sqlmock.NewResult(0, 1))
+	del := `DELETE FROM idempotency_keys WHERE user_id = (.+) AND key = (.+) AND status IS NULL`
+	mock.ExpectPrepare(del)
+	mock.ExpectExec(del).WithArgs("u1", "k2").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx := context.Background()
+	if err := con.Complete(ctx, "u1", "k1", feedback.Response{Status: 200, ContentType: "application/json", Body: []byte("{}")}); err != nil {
+		t.Fatal("Complete() error", err)
+	}
+	if err := con.Release(ctx, "u1", "k2"); err != nil {
+		t.Fatal("Release() error", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
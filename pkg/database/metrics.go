@@ -0,0 +1,36 @@
+package database
+
+import (
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/metrics"
+	"github.com/pkg/errors"
+)
+
+var (
+	queryDuration = metrics.NewHistogramVec(
+		"database_query_duration_seconds",
+		"Database query latency by operation.",
+		nil,
+		"operation",
+	)
+	queryErrors = metrics.NewCounterVec(
+		"database_query_errors_total",
+		"Failed database queries by operation.",
+		"operation",
+	)
+)
+
+// observe the latency and outcome of an operation started at start.
+// Expected outcomes like duplicate entries are not counted as errors.
+func observe(op string, start time.Time, err error) {
+	queryDuration.Observe(time.Since(start).Seconds(), op)
+	if err == nil {
+		return
+	}
+	if _, ok := errors.Cause(err).(*feedback.Error); ok {
+		return
+	}
+	queryErrors.Inc(op)
+}
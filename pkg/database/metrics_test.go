@@ -0,0 +1,25 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+)
+
+func TestObserve(t *testing.T) {
+	before := queryDuration.Count("test")
+	beforeErr := queryErrors.Value("test")
+
+	observe("test", time.Now(), nil)
+	observe("test", time.Now(), feedback.ErrDuplicateEntry)
+	observe("test", time.Now(), errors.New("connection refused"))
+
+	if got := queryDuration.Count("test") - before; got != 3 {
+		t.Errorf("observed latencies = %v want 3", got)
+	}
+	if got := queryErrors.Value("test") - beforeErr; got != 1 {
+		t.Errorf("errors = %v want 1", got)
+	}
+}
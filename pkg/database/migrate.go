@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// migrationLock is the advisory lock key held while migrating, so concurrently starting replicas
+// don't apply the same migration twice
+const migrationLock = 7291534
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version       INT PRIMARY KEY,
+	name          VARCHAR(100) NOT null,
+	applied_at    TIMESTAMPTZ NOT null DEFAULT now()
+)`
+
+// MigrationStatus of a single migration
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// MigrateUp applies all pending migrations and returns the number of applied ones
+func (c *Connection) MigrateUp() (n int, err error) {
+	err = c.migrate(func(tx *sql.Tx, applied map[uint]time.Time) error {
+		for _, m := range Migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			c.Info("applying migration", zap.Uint("version", m.Version), zap.String("name", m.Name))
+			if _, err := tx.Exec(m.Up); err != nil {
+				return errors.Wrapf(err, "migration %d up", m.Version)
+			}
+			if _, err := tx.Exec(
+				"INSERT INTO schema_migrations(version, name) VALUES ($1, $2)",
+				m.Version, m.Name,
+			); err != nil {
+				return errors.Wrapf(err, "migration %d record", m.Version)
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// MigrateDown reverts the latest applied migration and returns its version, or 0 if none was applied
+func (c *Connection) MigrateDown() (version uint, err error) {
+	err = c.migrate(func(tx *sql.Tx, applied map[uint]time.Time) error {
+		for i := len(Migrations) - 1; i >= 0; i-- {
+			m := Migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			c.Info("reverting migration", zap.Uint("version", m.Version), zap.String("name", m.Name))
+			if _, err := tx.Exec(m.Down); err != nil {
+				return errors.Wrapf(err, "migration %d down", m.Version)
+			}
+			if _, err := tx.Exec(
+				"DELETE FROM schema_migrations WHERE version = $1",
+				m.Version,
+			); err != nil {
+				return errors.Wrapf(err, "migration %d record", m.Version)
+			}
+			version = m.Version
+			return nil
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// MigrationStatus lists all known migrations and whether they have been applied
+func (c *Connection) MigrationStatus() (status []MigrationStatus, err error) {
+	err = c.migrate(func(tx *sql.Tx, applied map[uint]time.Time) error {
+		for _, m := range Migrations {
+			at, ok := applied[m.Version]
+			status = append(status, MigrationStatus{
+				Migration: m,
+				Applied:   ok,
+				AppliedAt: at,
+			})
+		}
+		return nil
+	})
+	return status, err
+}
+
+// CheckMigrations fails if any known migration has not been applied yet, without taking the migration lock
+func (c *Connection) CheckMigrations(ctx context.Context) error {
+	tx, err := c.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return errors.Wrap(err, "begin error")
+	}
+	defer tx.Rollback()
+
+	applied, err := appliedMigrations(tx)
+	if err != nil {
+		return err
+	}
+	var pending int
+	for _, m := range Migrations {
+		if _, ok := applied[m.Version]; !ok {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d pending migrations", pending)
+	}
+	return nil
+}
+
+func (c *Connection) migrate(f func(*sql.Tx, map[uint]time.Time) error) (err error) {
+	tx, err := c.Begin()
+	if err != nil {
+		return errors.Wrap(err, "begin error")
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				c.Error("rollback error", zap.Error(rbErr))
+			}
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLock); err != nil {
+		return errors.Wrap(err, "lock error")
+	}
+	if _, err := tx.Exec(createMigrationsTable); err != nil {
+		return errors.Wrap(err, "create migrations table error")
+	}
+	applied, err := appliedMigrations(tx)
+	if err != nil {
+		return err
+	}
+	return f(tx, applied)
+}
+
+func appliedMigrations(tx *sql.Tx) (map[uint]time.Time, error) {
+	rows, err := tx.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, errors.Wrap(err, "query migrations error")
+	}
+	defer rows.Close()
+
+	applied := make(map[uint]time.Time)
+	for rows.Next() {
+		var (
+			version uint
+			at      time.Time
+		)
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, errors.Wrap(err, "row scan error")
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
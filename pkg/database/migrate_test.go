@@ -0,0 +1,215 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/playnet-public/libs/log"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestMigrations(t *testing.T) {
+	var last uint
+	for _, m := range Migrations {
+		if m.Version <= last {
+			t.Fatalf("migration %d not in ascending order after %d", m.Version, last)
+		}
+		if m.Name == "" || m.Up == "" || m.Down == "" {
+			t.Fatalf("migration %d incomplete", m.Version)
+		}
+		last = m.Version
+	}
+}
+
+func expectMigrationSetup(mock sqlmock.Sqlmock, applied ...uint) {
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version", "applied_at"})
+	for _, v := range applied {
+		rows = rows.AddRow(v, time.Now())
+	}
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(rows)
+}
+
+func TestConnection_MigrateUp(t *testing.T) {
+	tests := []struct {
+		name    string
+		applied []uint
+		want    int
+	}{
+		{"fresh", nil, len(Migrations)},
+		{"upToDate", allVersions(), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			con := New(log.NewNop())
+			con.DB = db
+
+			expectMigrationSetup(mock, tt.applied...)
+			for i := 0; i < tt.want; i++ {
+				mock.ExpectExec(".+").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("INSERT INTO schema_migrations").
+					WithArgs(Migrations[i].Version, Migrations[i].Name).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			mock.ExpectCommit()
+
+			n, err := con.MigrateUp()
+			if err != nil {
+				t.Fatal("MigrateUp() error", err)
+			}
+			if n != tt.want {
+				t.Fatalf("MigrateUp() = %d want %d", n, tt.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal("expectations not met", err)
+			}
+		})
+	}
+}
+
+func TestConnection_MigrateUpError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	con := New(log.NewNop())
+	con.DB = db
+
+	expectMigrationSetup(mock)
+	mock.ExpectExec(".+").WillReturnError(errors.New("test error"))
+	mock.ExpectRollback()
+
+	if n, err := con.MigrateUp(); err == nil || n != 0 {
+		t.Fatalf("MigrateUp() = %d, %v want error", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal("expectations not met", err)
+	}
+}
+
+func TestConnection_MigrateDown(t *testing.T) {
+	tests := []struct {
+		name    string
+		applied []uint
+		want    uint
+	}{
+		{"nothingApplied", nil, 0},
+		{"latest", allVersions(), Migrations[len(Migrations)-1].Version},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			con := New(log.NewNop())
+			con.DB = db
+
+			expectMigrationSetup(mock, tt.applied...)
+			if tt.want > 0 {
+				mock.ExpectExec(".+").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("DELETE FROM schema_migrations").
+					WithArgs(tt.want).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			mock.ExpectCommit()
+
+			v, err := con.MigrateDown()
+			if err != nil {
+				t.Fatal("MigrateDown() error", err)
+			}
+			if v != tt.want {
+				t.Fatalf("MigrateDown() = %d want %d", v, tt.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal("expectations not met", err)
+			}
+		})
+	}
+}
+
+func TestConnection_MigrationStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	con := New(log.NewNop())
+	con.DB = db
+
+	expectMigrationSetup(mock, Migrations[0].Version)
+	mock.ExpectCommit()
+
+	status, err := con.MigrationStatus()
+	if err != nil {
+		t.Fatal("MigrationStatus() error", err)
+	}
+	if len(status) != len(Migrations) {
+		t.Fatalf("MigrationStatus() returned %d migrations want %d", len(status), len(Migrations))
+	}
+	if !status[0].Applied || status[0].AppliedAt.IsZero() {
+		t.Fatalf("MigrationStatus() = %v want first migration applied", status[0])
+	}
+	for _, s := range status[1:] {
+		if s.Applied {
+			t.Fatalf("MigrationStatus() = %v want pending", s)
+		}
+	}
+}
+
+func allVersions() []uint {
+	var versions []uint
+	for _, m := range Migrations {
+		versions = append(versions, m.Version)
+	}
+	return versions
+}
+
+func TestConnection_CheckMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		applied []uint
+		wantErr bool
+	}{
+		{"upToDate", allVersions(), false},
+		{"pending", allVersions()[:1], true},
+		{"fresh", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			con := New(log.NewNop())
+			con.DB = db
+
+			rows := sqlmock.NewRows([]string{"version", "applied_at"})
+			for _, v := range tt.applied {
+				rows = rows.AddRow(v, time.Now())
+			}
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(rows)
+			mock.ExpectRollback()
+
+			if err := con.CheckMigrations(context.Background()); (err != nil) != tt.wantErr {
+				t.Fatalf("CheckMigrations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package database
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migrations known to this binary, ordered by version.
+// Applied migrations must never be changed, add a new one instead.
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create entries",
+		Up: `CREATE TABLE IF NOT EXISTS entries (
+	id            serial,
+	session_id    VARCHAR(50) NOT null,
+	user_id       VARCHAR(50) NOT null,
+	rating        INT8 NOT null,
+	comment       VARCHAR(50),
+	PRIMARY key (session_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS entries_id ON entries (id);`,
+		Down: `DROP TABLE IF EXISTS entries;`,
+	},
+	{
+		Version: 2,
+		Name:    "add entries created_at",
+		Up: `ALTER TABLE entries ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT null DEFAULT now();
+CREATE INDEX IF NOT EXISTS entries_created_at ON entries (created_at);`,
+		Down: `DROP INDEX IF EXISTS entries_created_at;
+ALTER TABLE entries DROP COLUMN IF EXISTS created_at;`,
+	},
+	{
+		Version: 3,
+		Name:    "add entries updated_at",
+		Up: `ALTER TABLE entries ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+UPDATE entries SET updated_at = created_at WHERE updated_at IS NULL;
+ALTER TABLE entries ALTER COLUMN updated_at SET NOT null, ALTER COLUMN updated_at SET DEFAULT now();`,
+		Down: `ALTER TABLE entries DROP COLUMN IF EXISTS updated_at;`,
+	},
+	{
+		Version: 4,
+		Name:    "create rate_limits",
+		Up: `CREATE TABLE IF NOT EXISTS rate_limits (
+	key           VARCHAR(200) PRIMARY KEY,
+	tokens        DOUBLE PRECISION NOT null,
+	updated_at    TIMESTAMPTZ NOT null DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS rate_limits_updated_at ON rate_limits (updated_at);`,
+		Down: `DROP TABLE IF EXISTS rate_limits;`,
+	},
+	{
+		Version: 5,
+		Name:    "create idempotency_keys",
+		Up: `CREATE TABLE IF NOT EXISTS idempotency_keys (
+	user_id       VARCHAR(200) NOT null,
+	key           VARCHAR(100) NOT null,
+	request_hash  CHAR(64) NOT null,
+	status        INT,
+	content_type  VARCHAR(100),
+	body          BYTEA,
+	created_at    TIMESTAMPTZ NOT null DEFAULT now(),
+	PRIMARY KEY (user_id, key)
+);
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_at ON idempotency_keys (created_at);`,
+		Down: `DROP TABLE IF EXISTS idempotency_keys;`,
+	},
+	{
+		Version: 6,
+		Name:    "create sessions",
+		Up: `CREATE TABLE IF NOT EXISTS sessions (
+	id                     VARCHAR(50) PRIMARY KEY,
+	started_at             TIMESTAMPTZ NOT null,
+	ended_at               TIMESTAMPTZ,
+	rating_window_seconds  INT8 NOT null,
+	updated_at             TIMESTAMPTZ NOT null DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS session_participants (
+	session_id    VARCHAR(50) NOT null REFERENCES sessions (id) ON DELETE CASCADE,
+	user_id       VARCHAR(50) NOT null,
+	PRIMARY KEY (session_id, user_id)
+);`,
+		Down: `DROP TABLE IF EXISTS session_participants;
+DROP TABLE IF EXISTS sessions;`,
+	},
+	{
+		Version: 7,
+		Name:    "add idempotency_keys reserved_at",
+		Up:      `ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS reserved_at TIMESTAMPTZ NOT null DEFAULT now();`,
+		Down:    `ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS reserved_at;`,
+	},
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// driverName of the database/sql driver used by Open
+const driverName = "postgres"
+
+const (
+	initialBackoff = 250 * time.Millisecond
+	maxBackoff     = 10 * time.Second
+
+	// defaultMaxIdleConns of database/sql, restored after dropping idle connections
+	defaultMaxIdleConns = 2
+)
+
+// nextBackoff doubles d up to maxBackoff
+func nextBackoff(d time.Duration) time.Duration {
+	d *= 2
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+// pool of the latest opened connection, reported through the pool metrics
+var pool struct {
+	sync.Mutex
+	db *sql.DB
+}
+
+func poolStats() sql.DBStats {
+	pool.Lock()
+	defer pool.Unlock()
+	if pool.db == nil {
+		return sql.DBStats{}
+	}
+	return pool.db.Stats()
+}
+
+var (
+	_ = metrics.NewGaugeFunc(
+		"database_pool_max_open_connections",
+		"Maximum number of open connections to the database.",
+		func() float64 { return float64(poolStats().MaxOpenConnections) },
+	)
+	_ = metrics.NewGaugeFunc(
+		"database_pool_open_connections",
+		"Established connections to the database, both in use and idle.",
+		func() float64 { return float64(poolStats().OpenConnections) },
+	)
+	_ = metrics.NewGaugeFunc(
+		"database_pool_in_use_connections",
+		"Connections currently in use.",
+		func() float64 { return float64(poolStats().InUse) },
+	)
+	_ = metrics.NewGaugeFunc(
+		"database_pool_idle_connections",
+		"Idle connections.",
+		func() float64 { return float64(poolStats().Idle) },
+	)
+	_ = metrics.NewCounterFunc(
+		"database_pool_wait_count_total",
+		"Connections waited for because the pool was exhausted.",
+		func() float64 { return float64(poolStats().WaitCount) },
+	)
+	_ = metrics.NewCounterFunc(
+		"database_pool_wait_duration_seconds_total",
+		"Time spent waiting for a connection.",
+		func() float64 { return poolStats().WaitDuration.Seconds() },
+	)
+	_ = metrics.NewCounterFunc(
+		"database_pool_max_lifetime_closed_total",
+		"Connections closed because of ConnMaxLifetime.",
+		func() float64 { return float64(poolStats().MaxLifetimeClosed) },
+	)
+)
+
+// open a pool for driver and con, retrying the first connection with exponential backoff for ConnectTimeout
+func (c *Connection) open(driver, con string) (*sql.DB, error) {
+	db, err := sql.Open(driver, con)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	if c.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(c.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+
+	ctx := context.Background()
+	if c.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.ConnectTimeout)
+		defer cancel()
+	}
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		err = db.PingContext(ctx)
+		if err == nil {
+			break
+		}
+		if deadline, ok := ctx.Deadline(); !ok || time.Now().Add(backoff).After(deadline) {
+			db.Close()
+			return nil, err
+		}
+		c.Warn("database not reachable, retrying",
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+		time.Sleep(backoff)
+		backoff = nextBackoff(backoff)
+	}
+
+	pool.Lock()
+	pool.db = db
+	pool.Unlock()
+	return db, nil
+}
+
+// Monitor pings the database every interval until ctx is done, logging outages. Once the database
+// is back, idle connections are dropped so requests don't run into connections broken by the outage.
+func (c *Connection) Monitor(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	up := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, interval)
+		err := c.PingContext(pingCtx)
+		cancel()
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return
+		case err != nil && up:
+			c.Error("database unavailable", zap.Error(err))
+			up = false
+		case err == nil && !up:
+			c.Info("database recovered")
+			c.dropIdle()
+			up = true
+		}
+	}
+}
+
+// dropIdle closes all idle connections of the pool
+func (c *Connection) dropIdle() {
+	n := c.MaxIdleConns
+	if n <= 0 {
+		n = defaultMaxIdleConns
+	}
+	c.SetMaxIdleConns(-1)
+	c.SetMaxIdleConns(n)
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/playnet-public/libs/log"
+)
+
+// flakyDriver fails to connect for the first failures attempts and while down is set
+type flakyDriver struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	down     int32
+}
+
+var flakyDrivers int32
+
+// register d under a name unique to the test
+func (d *flakyDriver) register() string {
+	name := fmt.Sprintf("flaky-%d", atomic.AddInt32(&flakyDrivers, 1))
+	sql.Register(name, d)
+	return name
+}
+
+func (d *flakyDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.attempts++
+	if d.attempts <= d.failures || atomic.LoadInt32(&d.down) == 1 {
+		return nil, errors.New("connection refused")
+	}
+	return &flakyConn{d}, nil
+}
+
+type flakyConn struct {
+	d *flakyDriver
+}
+
+func (c *flakyConn) Ping(ctx context.Context) error {
+	if atomic.LoadInt32(&c.d.down) == 1 {
+		return errors.New("connection reset by peer")
+	}
+	return nil
+}
+
+func (c *flakyConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c *flakyConn) Close() error {
+	return nil
+}
+
+func (c *flakyConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestNextBackoff(t *testing.T) {
+	d := initialBackoff
+	for i := 0; i < 10; i++ {
+		next := nextBackoff(d)
+		if next != 2*d && next != maxBackoff {
+			t.Fatalf("nextBackoff(%v) = %v", d, next)
+		}
+		d = next
+	}
+	if d != maxBackoff {
+		t.Fatalf("backoff = %v, want it capped at %v", d, maxBackoff)
+	}
+}
+
+func TestConnection_openRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		timeout      time.Duration
+		wantErr      bool
+		wantAttempts int
+	}{
+		{"immediate", 0, 0, false, 1},
+		{"noRetry", 1, 0, true, 1},
+		{"retried", 2, 5 * time.Second, false, 3},
+		{"timeout", 10, initialBackoff / 2, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &flakyDriver{failures: tt.failures}
+			con := New(log.NewNop())
+			con.ConnectTimeout = tt.timeout
+			con.MaxOpenConns = 3
+
+			db, err := con.open(d.register(), "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if d.attempts != tt.wantAttempts {
+				t.Errorf("open() connected %d times want %d", d.attempts, tt.wantAttempts)
+			}
+			if err != nil {
+				return
+			}
+			defer db.Close()
+			if got := db.Stats().MaxOpenConnections; got != 3 {
+				t.Errorf("MaxOpenConnections = %d want 3", got)
+			}
+			if got := poolStats().MaxOpenConnections; got != 3 {
+				t.Errorf("pool metrics report %d max connections want 3", got)
+			}
+		})
+	}
+}
+
+func TestConnection_Monitor(t *testing.T) {
+	d := &flakyDriver{}
+	con := New(log.NewNop())
+	db, err := con.open(d.register(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	con.DB = db
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		con.Monitor(ctx, 5*time.Millisecond)
+		close(done)
+	}()
+
+	// the idle connection survives the outage, but is dropped once the database is back
+	atomic.StoreInt32(&d.down, 1)
+	time.Sleep(50 * time.Millisecond)
+	atomic.StoreInt32(&d.down, 0)
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	<-done
+	if db.Stats().MaxIdleClosed < 1 {
+		t.Fatal("idle connections not dropped after recovery")
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatal("connection not usable after recovery", err)
+	}
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/ratelimit"
+	"go.uber.org/zap"
+)
+
+// Take a token from the rate limit bucket of key, which is shared by all instances of the service.
+// The bucket is updated in a single statement following ratelimit.Take, using the clock of the database.
+func (c *Connection) Take(ctx context.Context, key string, l ratelimit.Limit) (d ratelimit.Decision, err error) {
+	defer func(start time.Time) { observe("rate_limit", start, err) }(time.Now())
+
+	query := `INSERT INTO rate_limits AS r (key, tokens, updated_at) VALUES ($1, $2::float8 - 1, now())
+	ON CONFLICT (key) DO UPDATE SET
+		tokens = GREATEST(-1, LEAST($2::float8, r.tokens + EXTRACT(EPOCH FROM now() - r.updated_at)::float8 * $3::float8) - 1),
+		updated_at = now()
+	RETURNING tokens`
+	statement, err := c.prepare(ctx, query)
+	if err != nil {
+		c.Error("statement error", zap.String("key", key), zap.Error(err))
+		return d, err
+	}
+
+	span := startSpan(ctx, "rate_limit", query)
+	var tokens float64
+	err = statement.QueryRowContext(ctx, key, l.Burst, l.Rate()).Scan(&tokens)
+	finishSpan(span, 1, err)
+	if err != nil {
+		c.Error("rate limit error", zap.String("key", key), zap.Error(err))
+		return d, err
+	}
+	return ratelimit.Decide(l, tokens), nil
+}
+
+// ExpireRateLimits deletes rate limit buckets untouched for maxAge every maxAge until ctx is done.
+// maxAge has to exceed the time it takes to refill the buckets, which then behave like missing ones.
+func (c *Connection) ExpireRateLimits(ctx context.Context, maxAge time.Duration) {
+	t := time.NewTicker(maxAge)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		res, err := c.ExecContext(ctx, `DELETE FROM rate_limits WHERE updated_at < now() - $1 * interval '1 second'`, maxAge.Seconds())
+		if err != nil {
+			if ctx.Err() == nil {
+				c.Error("expiring rate limits failed", zap.Error(err))
+			}
+			continue
+		}
+		c.Debug("expired rate limits", zap.Int64("buckets", rowsAffected(res)))
+	}
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/ratelimit"
+	"github.com/playnet-public/libs/log"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestConnection_Take(t *testing.T) {
+	l := ratelimit.Limit{Burst: 10, Period: 10 * time.Second}
+	query := `INSERT INTO rate_limits AS r (.+) ON CONFLICT \(key\) DO UPDATE SET (.+) RETURNING tokens`
+	tests := []struct {
+		name        string
+		tokens      float64
+		err         error
+		wantAllowed bool
+		wantErr     bool
+	}{
+		{"allowed", 4, nil, true, false},
+		{"denied", -0.5, nil, false, false},
+		{"error", 0, errors.New("connection reset"), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			con := New(log.NewNop())
+			con.DB = db
+
+			mock.ExpectPrepare(query)
+			exp := mock.ExpectQuery(query).WithArgs("user:1", 10, 1.0)
+			if tt.err != nil {
+				exp.WillReturnError(tt.err)
+			} else {
+				exp.WillReturnRows(sqlmock.NewRows([]string{"tokens"}).AddRow(tt.tokens))
+			}
+
+			d, err := con.Take(context.Background(), "user:1", l)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Take() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if d != ratelimit.Decide(l, tt.tokens) && err == nil {
+				t.Errorf("Take() = %+v want %+v", d, ratelimit.Decide(l, tt.tokens))
+			}
+			if d.Allowed != tt.wantAllowed {
+				t.Errorf("Take() allowed = %v want %v", d.Allowed, tt.wantAllowed)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestConnection_ExpireRateLimits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	con := New(log.NewNop())
+	con.DB = db
+
+	executed := make(chan struct{}, 1)
+	mock.ExpectExec("DELETE FROM rate_limits WHERE updated_at < (.+)").
+		WithArgs(signalArg{value: 0.01, matched: executed}).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		con.ExpireRateLimits(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+	<-executed
+	cancel()
+	<-done
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// signalArg matches value, reporting the match on matched without blocking
+type signalArg struct {
+	value   driver.Value
+	matched chan struct{}
+}
+
+func (a signalArg) Match(v driver.Value) bool {
+	if v != a.value {
+		return false
+	}
+	select {
+	case a.matched <- struct{}{}:
+	default:
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// PutSession registers session, replacing an earlier registration and its participants in a single transaction
+func (c *Connection) PutSession(ctx context.Context, session feedback.Session) (err error) {
+	defer func(start time.Time) { observe("put_session", start, err) }(time.Now())
+	c.Debug("registering session",
+		zap.String("session", session.ID),
+		zap.Int("participants", len(session.Participants)),
+	)
+
+	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin error")
+	}
+	defer func() {
+		if err != nil {
+			c.Error("registering session failed", zap.String("session", session.ID), zap.Error(err))
+			if rbErr := tx.Rollback(); rbErr != nil {
+				c.Error("rollback error", zap.Error(rbErr))
+			}
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	upsert := `INSERT INTO sessions (id, started_at, ended_at, rating_window_seconds) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (id) DO UPDATE SET
+		started_at = EXCLUDED.started_at,
+		ended_at = EXCLUDED.ended_at,
+		rating_window_seconds = EXCLUDED.rating_window_seconds,
+		updated_at = now()`
+	var endedAt *time.Time
+	if !session.EndedAt.IsZero() {
+		endedAt = &session.EndedAt
+	}
+	if err := c.execTx(ctx, tx, "put_session", upsert, session.ID, session.StartedAt, endedAt, int64(session.RatingWindow/time.Second)); err != nil {
+		return err
+	}
+	if err := c.execTx(ctx, tx, "delete_participants", `DELETE FROM session_participants WHERE session_id = $1`, session.ID); err != nil {
+		return err
+	}
+	insert := `INSERT INTO session_participants (session_id, user_id) SELECT $1, unnest($2::text[])`
+	return c.execTx(ctx, tx, "insert_participants", insert, session.ID, pq.Array(session.Participants))
+}
+
+// execTx runs query within tx, tracing it as op
+func (c *Connection) execTx(ctx context.Context, tx *sql.Tx, op, query string, args ...interface{}) error {
+	span := startSpan(ctx, op, query)
+	res, err := tx.ExecContext(ctx, query, args...)
+	finishSpan(span, rowsAffected(res), err)
+	return err
+}
+
+// GetSession registered as id, with its participants ordered by user id
+func (c *Connection) GetSession(ctx context.Context, id string) (session feedback.Session, err error) {
+	defer func(start time.Time) { observe("get_session", start, err) }(time.Now())
+
+	query := `SELECT s.started_at, s.ended_at, s.rating_window_seconds,
+		array_remove(array_agg(p.user_id ORDER BY p.user_id), NULL)
+	FROM sessions s LEFT JOIN session_participants p ON p.session_id = s.id
+	WHERE s.id = $1 GROUP BY s.id`
+	var participants pq.StringArray
+	session, err = c.getSession(ctx, "get_session", query, id, &participants, id)
+	if err != nil {
+		return session, err
+	}
+	session.Participants = []string(participants)
+	return session, nil
+}
+
+// GetParticipation returns the session id without its participants and whether userID takes part in it
+func (c *Connection) GetParticipation(ctx context.Context, id, userID string) (session feedback.Session, participant bool, err error) {
+	defer func(start time.Time) { observe("get_participation", start, err) }(time.Now())
+
+	query := `SELECT started_at, ended_at, rating_window_seconds,
+		EXISTS (SELECT 1 FROM session_participants WHERE session_id = $1 AND user_id = $2)
+	FROM sessions WHERE id = $1`
+	session, err = c.getSession(ctx, "get_participation", query, id, &participant, id, userID)
+	return session, participant, err
+}
+
+// getSession id with query, scanning its last column into extra
+func (c *Connection) getSession(ctx context.Context, op, query, id string, extra interface{}, args ...interface{}) (feedback.Session, error) {
+	session := feedback.Session{ID: id}
+	statement, err := c.prepare(ctx, query)
+	if err != nil {
+		c.Error("statement error", zap.String("session", session.ID), zap.Error(err))
+		return session, err
+	}
+
+	span := startSpan(ctx, op, query)
+	var (
+		endedAt pq.NullTime
+		window  int64
+	)
+	err = statement.QueryRowContext(ctx, args...).Scan(&session.StartedAt, &endedAt, &window, extra)
+	finishSpan(span, 1, err)
+	if err == sql.ErrNoRows {
+		return session, feedback.ErrUnknownSession
+	}
+	if err != nil {
+		c.Error("get session failed", zap.String("session", session.ID), zap.Error(err))
+		return session, err
+	}
+	if endedAt.Valid {
+		session.EndedAt = endedAt.Time
+	}
+	session.RatingWindow = time.Duration(window) * time.Second
+	return session, nil
+}
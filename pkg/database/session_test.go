@@ -0,0 +1,145 @@
+package database
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback/feedbacktest"
+	"github.com/playnet-public/libs/log"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// TestConnection_SessionStore runs the session conformance suite against a real database if TEST_DB_DSN is set
+func TestConnection_SessionStore(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN not set")
+	}
+	feedbacktest.TestSessionStore(t, func(t *testing.T) feedback.SessionStore {
+		con := New(log.NewNop())
+		con.AutoMigrate = true
+		if err := con.Open(dsn); err != nil {
+			t.Fatal("open error", err)
+		}
+		if _, err := con.Exec("TRUNCATE sessions CASCADE"); err != nil {
+			t.Fatal("truncate error", err)
+		}
+		return con
+	})
+}
+
+func TestConnection_PutSession(t *testing.T) {
+	start := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	upsert := `INSERT INTO sessions (.+) ON CONFLICT \(id\) DO UPDATE SET (.+)`
+	del := `DELETE FROM session_participants WHERE session_id = (.+)`
+	insert := `INSERT INTO session_participants (.+) SELECT (.+) unnest(.+)`
+	tests := []struct {
+		name    string
+		session feedback.Session
+		fail    bool
+	}{
+		{"running", feedback.Session{ID: "s1", Participants: []string{"u1"}, StartedAt: start, RatingWindow: time.Hour}, false},
+		{"ended", feedback.Session{ID: "s1", Participants: []string{"u1"}, StartedAt: start, EndedAt: start.Add(time.Hour), RatingWindow: time.Hour}, false},
+		{"rollback", feedback.Session{ID: "s1", Participants: []string{"u1"}, StartedAt: start, RatingWindow: time.Hour}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			con := New(log.NewNop())
+			con.DB = db
+
+			var endedAt interface{}
+			if !tt.session.EndedAt.IsZero() {
+				endedAt = tt.session.EndedAt
+			}
+			mock.ExpectBegin()
+			mock.ExpectExec(upsert).WithArgs("s1", start, endedAt, 3600).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(del).WithArgs("s1").WillReturnResult(sqlmock.NewResult(0, 2))
+			if tt.fail {
+				mock.ExpectExec(insert).WillReturnError(errConnectionReset)
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectExec(insert).WithArgs("s1", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			err = con.PutSession(context.Background(), tt.session)
+			if (err != nil) != tt.fail {
+				t.Fatalf("PutSession() error = %v, wantErr %v", err, tt.fail)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestConnection_GetSession(t *testing.T) {
+	start := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	query := `SELECT (.+) FROM sessions s LEFT JOIN session_participants p (.+) WHERE s.id = (.+)`
+	columns := []string{"started_at", "ended_at", "rating_window_seconds", "participants"}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	con := New(log.NewNop())
+	con.DB = db
+
+	mock.ExpectPrepare(query)
+	mock.ExpectQuery(query).WithArgs("s1").WillReturnRows(sqlmock.NewRows(columns).AddRow(start, start.Add(time.Hour), 600, []byte("{u1,u2}")))
+	mock.ExpectQuery(query).WithArgs("s2").WillReturnRows(sqlmock.NewRows(columns))
+
+	got, err := con.GetSession(context.Background(), "s1")
+	if err != nil {
+		t.Fatal("GetSession() error", err)
+	}
+	want := feedback.Session{ID: "s1", Participants: []string{"u1", "u2"}, StartedAt: start, EndedAt: start.Add(time.Hour), RatingWindow: 10 * time.Minute}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetSession() = %+v want %+v", got, want)
+	}
+	if _, err := con.GetSession(context.Background(), "s2"); err != feedback.ErrUnknownSession {
+		t.Errorf("GetSession() error = %v want %v", err, feedback.ErrUnknownSession)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConnection_GetParticipation(t *testing.T) {
+	start := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	query := `SELECT (.+) EXISTS \(SELECT 1 FROM session_participants (.+)\) FROM sessions WHERE id = (.+)`
+	columns := []string{"started_at", "ended_at", "rating_window_seconds", "exists"}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	con := New(log.NewNop())
+	con.DB = db
+
+	mock.ExpectPrepare(query)
+	mock.ExpectQuery(query).WithArgs("s1", "u1").WillReturnRows(sqlmock.NewRows(columns).AddRow(start, nil, 600, true))
+
+	got, participant, err := con.GetParticipation(context.Background(), "s1", "u1")
+	if err != nil {
+		t.Fatal("GetParticipation() error", err)
+	}
+	want := feedback.Session{ID: "s1", StartedAt: start, RatingWindow: 10 * time.Minute}
+	if !participant || !reflect.DeepEqual(got, want) {
+		t.Errorf("GetParticipation() = %+v, %v want %+v, true", got, participant, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
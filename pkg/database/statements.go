@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// statements prepared once per query and reused by all requests.
+// A *sql.Stmt is prepared again on its own whenever it runs on a connection it has not been
+// prepared on yet, so statements keep working after the pool reconnected to the database.
+type statements struct {
+	mu    sync.Mutex
+	db    *sql.DB
+	stmts map[string]*sql.Stmt
+}
+
+// prepare query or return the statement prepared for it before.
+// The lock is not held while preparing, so a slow database only delays requests preparing a new query.
+func (c *Connection) prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	db := c.DB
+	c.stmts.mu.Lock()
+	// statements belong to the pool they were prepared on
+	if c.stmts.db != db {
+		c.stmts.closeLocked()
+		c.stmts.db = db
+	}
+	stmt, ok := c.stmts.stmts[query]
+	c.stmts.mu.Unlock()
+	if ok {
+		return stmt, nil
+	}
+
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.stmts.mu.Lock()
+	defer c.stmts.mu.Unlock()
+	if c.stmts.db != db {
+		// the pool was replaced meanwhile, its statements are not kept
+		return stmt, nil
+	}
+	if existing, ok := c.stmts.stmts[query]; ok {
+		// prepared concurrently by another request, keep the first one
+		stmt.Close()
+		return existing, nil
+	}
+	if c.stmts.stmts == nil {
+		c.stmts.stmts = make(map[string]*sql.Stmt)
+	}
+	c.stmts.stmts[query] = stmt
+	c.Debug("prepared statement", zap.Int("statements", len(c.stmts.stmts)))
+	return stmt, nil
+}
+
+// close all prepared statements
+func (s *statements) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeLocked()
+}
+
+func (s *statements) closeLocked() (err error) {
+	for query, stmt := range s.stmts {
+		if cerr := stmt.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(s.stmts, query)
+	}
+	return err
+}
@@ -0,0 +1,270 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/playnet-public/libs/log"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestConnection_prepare(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	con := New(log.NewNop())
+	con.DB = db
+
+	query := "INSERT INTO entries(.+) VALUES (.+)"
+	mock.ExpectPrepare(query).WillBeClosed()
+	mock.ExpectExec(query).WithArgs("s1", "u1", 1, "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(query).WithArgs("s1", "u2", 2, "").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectClose()
+
+	ctx := context.Background()
+	if err := con.Add(ctx, feedback.Entry{SessionID: "s1", UserID: "u1", Rating: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := con.Add(ctx, feedback.Entry{SessionID: "s1", UserID: "u2", Rating: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := con.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal("statement not reused or not closed:", err)
+	}
+}
+
+func TestConnection_prepareAfterReconnect(t *testing.T) {
+	con := New(log.NewNop())
+	query := "INSERT INTO entries(.+) VALUES (.+)"
+	for i := 0; i < 2; i++ {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		con.DB = db
+
+		mock.ExpectPrepare(query)
+		mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(1, 1))
+		if err := con.Add(context.Background(), feedback.Entry{SessionID: "s1", UserID: "u1", Rating: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("statement not prepared on connection %d: %v", i, err)
+		}
+	}
+}
+
+func TestConnection_prepareError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	con := New(log.NewNop())
+	con.DB = db
+
+	query := "INSERT INTO entries(.+) VALUES (.+)"
+	mock.ExpectPrepare(query).WillReturnError(errors.New("connection reset"))
+	mock.ExpectPrepare(query)
+	mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	entry := feedback.Entry{SessionID: "s1", UserID: "u1", Rating: 1}
+	if err := con.Add(context.Background(), entry); err == nil {
+		t.Fatal("Add() should fail if the statement can't be prepared")
+	}
+	// failed statements are not cached
+	if err := con.Add(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// blockingDriver prepares statements, blocking each prepare of the query "slow" until release is closed
+type blockingDriver struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (d *blockingDriver) Open(name string) (driver.Conn, error) {
+	return &blockingConn{d}, nil
+}
+
+type blockingConn struct {
+	d *blockingDriver
+}
+
+func (c *blockingConn) Prepare(query string) (driver.Stmt, error) {
+	if query == "slow" {
+		c.d.entered <- struct{}{}
+		<-c.d.release
+	}
+	return &latencyStmt{&latencyDriver{}}, nil
+}
+
+func (c *blockingConn) Close() error {
+	return nil
+}
+
+func (c *blockingConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestConnection_prepareConcurrent(t *testing.T) {
+	d := &blockingDriver{entered: make(chan struct{}, 2), release: make(chan struct{})}
+	name := fmt.Sprintf("blocking-%d", atomic.AddInt32(&latencyDrivers, 1))
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	con := New(log.NewNop())
+	con.DB = db
+	ctx := context.Background()
+
+	cached, err := con.prepare(ctx, "fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts := make(chan *sql.Stmt, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			stmt, err := con.prepare(ctx, "slow")
+			if err != nil {
+				t.Error(err)
+			}
+			stmts <- stmt
+		}()
+	}
+	<-d.entered
+	<-d.entered
+
+	// cached statements are returned while others are being prepared
+	if stmt, err := con.prepare(ctx, "fast"); err != nil || stmt != cached {
+		t.Fatalf("prepare() of cached statement = %v, %v", stmt, err)
+	}
+	close(d.release)
+	// concurrent prepares of the same query end up with the same statement
+	if first, second := <-stmts, <-stmts; first != second {
+		t.Error("statements prepared concurrently are not deduplicated")
+	}
+}
+
+// latencyDriver simulates the network round trip to the database for each prepare and exec
+type latencyDriver struct {
+	rtt      time.Duration
+	prepared int64
+}
+
+func (d *latencyDriver) Open(name string) (driver.Conn, error) {
+	return &latencyConn{d}, nil
+}
+
+type latencyConn struct {
+	d *latencyDriver
+}
+
+func (c *latencyConn) Prepare(query string) (driver.Stmt, error) {
+	time.Sleep(c.d.rtt)
+	atomic.AddInt64(&c.d.prepared, 1)
+	return &latencyStmt{c.d}, nil
+}
+
+func (c *latencyConn) Close() error {
+	return nil
+}
+
+func (c *latencyConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not implemented")
+}
+
+type latencyStmt struct {
+	d *latencyDriver
+}
+
+func (s *latencyStmt) Close() error {
+	time.Sleep(s.d.rtt)
+	return nil
+}
+
+func (s *latencyStmt) NumInput() int {
+	return -1
+}
+
+func (s *latencyStmt) Exec(args []driver.Value) (driver.Result, error) {
+	time.Sleep(s.d.rtt)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *latencyStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not implemented")
+}
+
+var latencyDrivers int32
+
+// BenchmarkPostEntry measures POST /{sessionID} against a database answering after a round trip of 100µs,
+// comparing cached statements with preparing and closing them on every request.
+func BenchmarkPostEntry(b *testing.B) {
+	for _, cached := range []bool{true, false} {
+		b.Run("cached="+strconv.FormatBool(cached), func(b *testing.B) {
+			d := &latencyDriver{rtt: 100 * time.Microsecond}
+			name := fmt.Sprintf("latency-%d", atomic.AddInt32(&latencyDrivers, 1))
+			sql.Register(name, d)
+			db, err := sql.Open(name, "")
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer db.Close()
+
+			newHandler := func() (http.Handler, *Connection) {
+				con := New(log.NewNop())
+				con.DB = db
+				return feedback.New(log.NewNop(), con).Handler(), con
+			}
+			shared, sharedCon := newHandler()
+
+			var n int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				h, con := shared, sharedCon
+				if !cached {
+					// statements are closed after each request, which must not affect other goroutines
+					h, con = newHandler()
+				}
+				for pb.Next() {
+					user := strconv.FormatInt(atomic.AddInt64(&n, 1), 10)
+					r := httptest.NewRequest("POST", "/session", strings.NewReader(`{"rating": 5}`))
+					r.Header.Set("Ubi-UserId", user)
+					w := httptest.NewRecorder()
+					h.ServeHTTP(w, r)
+					if w.Code != http.StatusOK {
+						b.Fatalf("status = %d: %s", w.Code, w.Body)
+					}
+					if !cached {
+						con.stmts.close()
+					}
+				}
+			})
+			b.Logf("%d statements prepared for %d requests", atomic.LoadInt64(&d.prepared), b.N)
+		})
+	}
+}
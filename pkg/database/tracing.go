@@ -0,0 +1,40 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+)
+
+// startSpan for a single statement as child of the span in ctx
+func startSpan(ctx context.Context, op, query string) opentracing.Span {
+	span, _ := opentracing.StartSpanFromContext(ctx, "database."+op)
+	ext.SpanKindRPCClient.Set(span)
+	ext.DBType.Set(span, "sql")
+	ext.DBStatement.Set(span, query)
+	return span
+}
+
+// finishSpan recording the number of rows returned or affected by the statement
+func finishSpan(span opentracing.Span, rows int64, err error) {
+	span.SetTag("db.rows", rows)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+	}
+	span.Finish()
+}
+
+func rowsAffected(res sql.Result) int64 {
+	if res == nil {
+		return 0
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0
+	}
+	return n
+}
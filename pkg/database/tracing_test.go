@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/playnet-public/libs/log"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestConnection_Tracing(t *testing.T) {
+	tracer := mocktracer.New()
+	global := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(global)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	con := New(log.NewNop())
+	con.DB = db
+
+	insert := "INSERT INTO entries(.+) VALUES (.+)"
+	mock.ExpectPrepare(insert)
+	mock.ExpectExec(insert).WillReturnResult(sqlmock.NewResult(1, 1))
+	query := `SELECT (.+) FROM entries WHERE session_id = (.+)`
+	mock.ExpectPrepare(query)
+	mock.ExpectQuery(query).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "session_id", "user_id", "rating", "comment", "created_at", "updated_at"}).
+			AddRow(2, "abc123", "2", 2, "test", time.Now(), time.Now()).
+			AddRow(1, "abc123", "1", 5, "test", time.Now(), time.Now()),
+	)
+
+	parent := tracer.StartSpan("request")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+	if err := con.Add(ctx, feedback.Entry{SessionID: "abc123", UserID: "1", Rating: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := con.GetBySession(ctx, "abc123"); err != nil {
+		t.Fatal(err)
+	}
+	parent.Finish()
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 3 {
+		t.Fatalf("finished %d spans want 3", len(spans))
+	}
+	parentID := parent.Context().(mocktracer.MockSpanContext).SpanID
+	for i, want := range []struct {
+		op   string
+		rows int64
+	}{
+		{"database.add", 1},
+		{"database.get_by_session", 2},
+	} {
+		span := spans[i]
+		if span.OperationName != want.op {
+			t.Errorf("span %d = %q want %q", i, span.OperationName, want.op)
+		}
+		if span.ParentID != parentID {
+			t.Errorf("span %q is no child of the request span", span.OperationName)
+		}
+		if span.Tag("db.statement") == nil {
+			t.Errorf("span %q misses the statement", span.OperationName)
+		}
+		if got := span.Tag("db.rows"); got != want.rows {
+			t.Errorf("span %q rows = %v want %v", span.OperationName, got, want.rows)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package feedback
+
+import (
+	"context"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// UserIDHeader carries the id of the player sending a request
+const UserIDHeader = "Ubi-UserId"
+
+// Role of the sender of a request, granting it a set of permissions
+type Role string
+
+// Roles known to the service
+const (
+	// RolePlayer submits feedback and reads their own entries
+	RolePlayer Role = "player"
+	// RoleReader is used by live-ops tooling, reading all feedback without the ids of the players
+	RoleReader Role = "reader"
+	// RoleModerator reads all feedback including the ids of the players
+	RoleModerator Role = "moderator"
+	// RoleBackend is used by the game backend registering sessions
+	RoleBackend Role = "backend"
+	// RoleAdmin is allowed everything
+	RoleAdmin Role = "admin"
+)
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Permission to use a part of the API
+type Permission string
+
+// Permissions granted by roles
+const (
+	// PermSubmit allows adding and updating entries and reading them back, always as the user of the Identity
+	PermSubmit Permission = "submit"
+	// PermRead allows reading the entries and stats of all players
+	PermRead Permission = "read"
+	// PermReadUserIDs allows seeing which player sent an entry
+	PermReadUserIDs Permission = "read_user_ids"
+	// PermManageSessions allows registering sessions and reading their registration
+	PermManageSessions Permission = "manage_sessions"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RolePlayer:    {PermSubmit},
+	RoleReader:    {PermRead},
+	RoleModerator: {PermRead, PermReadUserIDs},
+	RoleBackend:   {PermManageSessions},
+	RoleAdmin:     {PermSubmit, PermRead, PermReadUserIDs, PermManageSessions},
+}
+
+// Identity of the sender of a request
+type Identity struct {
+	// UserID of the player, or the name of the client for other roles
+	UserID string
+	Role   Role
+}
+
+// Can reports whether the identity has permission p
+func (id Identity) Can(p Permission) bool {
+	for _, granted := range rolePermissions[id.Role] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator verifies the identity of the sender of a request.
+// Requests without valid credentials fail with ErrUnauthenticated.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Identity, error)
+}
+
+// AuthenticatorFunc adapts a function to the Authenticator interface
+type AuthenticatorFunc func(r *http.Request) (Identity, error)
+
+// Authenticate r by calling f
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (Identity, error) {
+	return f(r)
+}
+
+// TrustHeader takes the user id from the UserIDHeader without verifying it and grants every request
+// all permissions, as before roles were introduced. This allows anyone to send feedback as any player
+// and read all feedback, so it is only kept for migrating clients to signed identities.
+var TrustHeader = AuthenticatorFunc(func(r *http.Request) (Identity, error) {
+	return Identity{UserID: r.Header.Get(UserIDHeader), Role: RoleAdmin}, nil
+})
+
+// TrustPlayerHeader takes the user id from the UserIDHeader like TrustHeader, but only grants the player role.
+// It is used once live-ops tooling authenticates with API keys, so unverified requests can't list all feedback.
+var TrustPlayerHeader = AuthenticatorFunc(func(r *http.Request) (Identity, error) {
+	return Identity{UserID: r.Header.Get(UserIDHeader), Role: RolePlayer}, nil
+})
+
+type identityKey struct{}
+
+// identity of the sender of r, as verified by authorize or by authenticating r if the handler isn't wrapped by it
+func (s *Service) identity(r *http.Request) (Identity, error) {
+	if id, ok := r.Context().Value(identityKey{}).(Identity); ok {
+		return id, nil
+	}
+	return s.Auth.Authenticate(r)
+}
+
+// authorize h, only calling it for senders having permission p. The verified identity is passed on in
+// the request context.
+func (s *Service) authorize(p Permission, h handler) handler {
+	return func(w http.ResponseWriter, r *http.Request) (err error) {
+		id, err := s.Auth.Authenticate(r)
+		if err != nil {
+			authFailuresTotal.Inc()
+			s.deferError(w, r, err)
+			return err
+		}
+		if !id.Can(p) {
+			accessDeniedTotal.Inc(routeName(r), string(id.Role))
+			s.Warn("access denied",
+				zap.String("route", routeName(r)),
+				zap.String("method", r.Method),
+				zap.String("user", id.UserID),
+				zap.String("role", string(id.Role)),
+				zap.String("permission", string(p)),
+			)
+			s.deferError(w, r, ErrForbidden)
+			return ErrForbidden
+		}
+		return h(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	}
+}
+
+// redact the user ids of entries unless the sender of r may see them
+func (s *Service) redact(r *http.Request, entries []Entry) []Entry {
+	if id, err := s.identity(r); err == nil && id.Can(PermReadUserIDs) {
+		return entries
+	}
+	redacted := make([]Entry, len(entries))
+	for i, e := range entries {
+		e.UserID = ""
+		redacted[i] = e
+	}
+	return redacted
+}
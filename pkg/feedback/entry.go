@@ -1,10 +1,37 @@
 package feedback
 
+import "time"
+
 // Entry definition
 type Entry struct {
-	ID        string `json:"id"`
-	SessionID string `json:"sessionID"`
-	UserID    string `json:"userID"`
-	Rating    int8   `json:"rating"`
-	Comment   string `json:"comment"`
+	ID        string    `json:"id"`
+	SessionID string    `json:"sessionID"`
+	UserID    string    `json:"userID"`
+	Rating    int8      `json:"rating"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Range of creation times, Since is inclusive and Until exclusive.
+// A zero bound is unbounded.
+type Range struct {
+	Since time.Time
+	Until time.Time
+}
+
+// IsZero reports whether the range is unbounded
+func (r Range) IsZero() bool {
+	return r.Since.IsZero() && r.Until.IsZero()
+}
+
+// Contains reports whether t lies within the range
+func (r Range) Contains(t time.Time) bool {
+	if !r.Since.IsZero() && t.Before(r.Since) {
+		return false
+	}
+	if !r.Until.IsZero() && !t.Before(r.Until) {
+		return false
+	}
+	return true
 }
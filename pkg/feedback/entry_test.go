@@ -0,0 +1,30 @@
+package feedback
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRange_Contains(t *testing.T) {
+	t0 := time.Date(2018, 3, 17, 20, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		r    Range
+		t    time.Time
+		want bool
+	}{
+		{"unbounded", Range{}, t0, true},
+		{"sinceInclusive", Range{Since: t0}, t0, true},
+		{"beforeSince", Range{Since: t0}, t0.Add(-time.Second), false},
+		{"untilExclusive", Range{Until: t0}, t0, false},
+		{"beforeUntil", Range{Until: t0}, t0.Add(-time.Second), true},
+		{"between", Range{Since: t0, Until: t0.Add(time.Hour)}, t0.Add(time.Minute), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.Contains(tt.t); got != tt.want {
+				t.Errorf("Range.Contains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,14 +1,106 @@
 package feedback
 
-import "errors"
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Error returned to clients, carrying the http status, a stable machine-readable code
+// and a message that is safe to expose
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Wrap cause with the public error, the cause is only kept for logging
+func (e *Error) Wrap(cause error) error {
+	return &wrappedError{e, cause}
+}
+
+type wrappedError struct {
+	public *Error
+	cause  error
+}
+
+func (e *wrappedError) Error() string {
+	return e.public.Message + ": " + e.cause.Error()
+}
+
+// Cause returns the public error for errors.Cause
+func (e *wrappedError) Cause() error {
+	return e.public
+}
 
 var (
 	// ErrInvalidRating .
-	ErrInvalidRating = errors.New("rating invalid. has to be between 1-5")
+	ErrInvalidRating = &Error{http.StatusBadRequest, "invalid_rating", "rating invalid. has to be between 1-5"}
 	// ErrDuplicateEntry .
-	ErrDuplicateEntry = errors.New("entries may only be sent once per user/session")
+	ErrDuplicateEntry = &Error{http.StatusConflict, "duplicate_entry", "entries may only be sent once per user/session"}
 	// ErrNoSession .
-	ErrNoSession = errors.New("no sessionID provided")
+	ErrNoSession = &Error{http.StatusBadRequest, "missing_session", "no sessionID provided"}
 	// ErrNoUserID .
-	ErrNoUserID = errors.New("no userID provided")
+	ErrNoUserID = &Error{http.StatusBadRequest, "missing_user_id", "no userID provided"}
+	// ErrUnauthenticated is returned for requests without valid player credentials
+	ErrUnauthenticated = &Error{http.StatusUnauthorized, "unauthenticated", "missing or invalid credentials"}
+	// ErrForbidden is returned if the role of the sender doesn't allow the request
+	ErrForbidden = &Error{http.StatusForbidden, "forbidden", "access denied"}
+	// ErrInvalidIdempotencyKey .
+	ErrInvalidIdempotencyKey = &Error{http.StatusBadRequest, "invalid_idempotency_key", "invalid Idempotency-Key. has to be at most 100 characters"}
+	// ErrIdempotencyKeyReused is returned if an idempotency key is sent again with a different request
+	ErrIdempotencyKeyReused = &Error{http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency-Key was already used for a different request"}
+	// ErrIdempotencyKeyInUse is returned while the first request with an idempotency key is still in progress
+	ErrIdempotencyKeyInUse = &Error{http.StatusConflict, "idempotency_key_in_use", "a request with this Idempotency-Key is still in progress"}
+	// ErrUnknownSession is returned for sessions not registered by the game backend
+	ErrUnknownSession = &Error{http.StatusNotFound, "unknown_session", "session is not registered"}
+	// ErrNotParticipant is returned if a player rates a session they didn't take part in
+	ErrNotParticipant = &Error{http.StatusForbidden, "not_participant", "only participants of the session may rate it"}
+	// ErrSessionNotStarted is returned for ratings of sessions that haven't started yet
+	ErrSessionNotStarted = &Error{http.StatusConflict, "session_not_started", "the session has not started yet"}
+	// ErrRatingWindowClosed is returned for ratings sent after the rating window of the session
+	ErrRatingWindowClosed = &Error{http.StatusConflict, "rating_window_closed", "the rating window of the session has closed"}
+	// ErrNotFound .
+	ErrNotFound = &Error{http.StatusNotFound, "not_found", "no entry found for user/session"}
+	// ErrInvalidGroupBy .
+	ErrInvalidGroupBy = &Error{http.StatusBadRequest, "invalid_group_by", "invalid groupBy value. has to be session"}
+	// ErrInvalidCursor .
+	ErrInvalidCursor = &Error{http.StatusBadRequest, "invalid_cursor", "invalid cursor value"}
+	// ErrInvalidLimit .
+	ErrInvalidLimit = &Error{http.StatusBadRequest, "invalid_limit", "invalid limit value. has to be a positive integer"}
+	// ErrInvalidFilter .
+	ErrInvalidFilter = &Error{http.StatusBadRequest, "invalid_filter", "invalid filter value. has to be an integer"}
+	// ErrInvalidSince .
+	ErrInvalidSince = &Error{http.StatusBadRequest, "invalid_since", "invalid since value. has to be a RFC3339 timestamp"}
+	// ErrInvalidUntil .
+	ErrInvalidUntil = &Error{http.StatusBadRequest, "invalid_until", "invalid until value. has to be a RFC3339 timestamp"}
+	// ErrInvalidBody .
+	ErrInvalidBody = &Error{http.StatusBadRequest, "invalid_body", "request body is not valid JSON"}
+	// ErrBodyTooLarge .
+	ErrBodyTooLarge = &Error{http.StatusRequestEntityTooLarge, "body_too_large", "request body too large"}
+	// ErrValidation is returned for *ValidationError, listing the invalid fields
+	ErrValidation = &Error{http.StatusBadRequest, "validation_failed", "request validation failed"}
+	// ErrTimeout is returned if a request could not be served within Service.Timeout
+	ErrTimeout = &Error{http.StatusServiceUnavailable, "timeout", "request timed out"}
+	// ErrRateLimited is returned if a player, client or session sends too many submissions
+	ErrRateLimited = &Error{http.StatusTooManyRequests, "rate_limited", "rate limit exceeded, retry later"}
+	// ErrOverloaded is returned if submissions come in faster than they can be stored
+	ErrOverloaded = &Error{http.StatusServiceUnavailable, "overloaded", "too many submissions, retry later"}
+	// ErrInternal is returned to clients for all errors not of type *Error
+	ErrInternal = &Error{http.StatusInternalServerError, "internal", "internal server error"}
 )
+
+// publicError returns the *Error behind err, or ErrInternal if there is none
+func publicError(err error) *Error {
+	switch e := errors.Cause(err).(type) {
+	case *Error:
+		return e
+	case *ValidationError:
+		return ErrValidation
+	}
+	return ErrInternal
+}
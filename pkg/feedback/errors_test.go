@@ -0,0 +1,38 @@
+package feedback
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestPublicError(t *testing.T) {
+	_, strconvErr := strconv.Atoi("a")
+	tests := []struct {
+		name string
+		in   error
+		want *Error
+	}{
+		{"typed", ErrInvalidRating, ErrInvalidRating},
+		{"wrapped", ErrInvalidLimit.Wrap(strconvErr), ErrInvalidLimit},
+		{"plain", errors.New("test error"), ErrInternal},
+		{"database", &pq.Error{Code: "42P01", Message: "relation \"entries\" does not exist"}, ErrInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := publicError(tt.in); got != tt.want {
+				t.Errorf("publicError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestError_Wrap(t *testing.T) {
+	_, cause := strconv.Atoi("a")
+	err := ErrInvalidFilter.Wrap(cause)
+	if want := ErrInvalidFilter.Message + ": " + cause.Error(); err.Error() != want {
+		t.Fatalf("Wrap().Error() = %q want %q", err.Error(), want)
+	}
+}
@@ -0,0 +1,94 @@
+package feedbacktest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+)
+
+// IdempotencyFactory returns an empty IdempotencyStore for a single test, closed afterwards if it is an io.Closer
+type IdempotencyFactory func(t *testing.T) feedback.IdempotencyStore
+
+// TestIdempotencyStore runs the shared IdempotencyStore behaviour tests against stores created by newStore
+func TestIdempotencyStore(t *testing.T, newStore IdempotencyFactory) {
+	ctx := context.Background()
+	resp := feedback.Response{Status: 200, ContentType: "application/json; charset=utf-8", Body: []byte("{}")}
+
+	t.Run("replay", func(t *testing.T) {
+		s := newStore(t)
+		defer closeStore(s)
+		mustReserve(t, s, "u1", "k1", "h1", true)
+		if rec := mustReserve(t, s, "u1", "k1", "h2", false); rec.Hash != "h1" || rec.Response != nil {
+			t.Fatalf("Reserve() in progress = %+v want hash h1 without response", rec)
+		}
+		if err := s.Complete(ctx, "u1", "k1", resp); err != nil {
+			t.Fatal("Complete() error", err)
+		}
+		rec := mustReserve(t, s, "u1", "k1", "h1", false)
+		if rec.Response == nil || !reflect.DeepEqual(*rec.Response, resp) {
+			t.Fatalf("Reserve() response = %+v want %+v", rec.Response, resp)
+		}
+		// completed keys stay reserved
+		if err := s.Release(ctx, "u1", "k1"); err != nil {
+			t.Fatal("Release() error", err)
+		}
+		mustReserve(t, s, "u1", "k1", "h1", false)
+	})
+	t.Run("release", func(t *testing.T) {
+		s := newStore(t)
+		defer closeStore(s)
+		mustReserve(t, s, "u1", "k1", "h1", true)
+		if err := s.Release(ctx, "u1", "k1"); err != nil {
+			t.Fatal("Release() error", err)
+		}
+		mustReserve(t, s, "u1", "k1", "h2", true)
+	})
+	t.Run("abandoned", func(t *testing.T) {
+		s := newStore(t)
+		defer closeStore(s)
+		mustReserve(t, s, "u1", "k1", "h1", true)
+		mustReserve(t, s, "u1", "k2", "h1", true)
+		if err := s.Complete(ctx, "u1", "k2", resp); err != nil {
+			t.Fatal("Complete() error", err)
+		}
+		time.Sleep(2 * testLease)
+		// taken over by a retry, as the request holding it is gone
+		if _, ok, err := s.Reserve(ctx, "u1", "k1", "h1", testLease); err != nil || !ok {
+			t.Fatalf("Reserve() of abandoned key = %v, %v want it reserved", ok, err)
+		}
+		if _, ok, err := s.Reserve(ctx, "u1", "k1", "h1", testLease); err != nil || ok {
+			t.Fatalf("Reserve() of taken over key = %v, %v want it in progress", ok, err)
+		}
+		if _, ok, err := s.Reserve(ctx, "u1", "k2", "h1", testLease); err != nil || ok {
+			t.Fatalf("Reserve() of completed key = %v, %v want it replayed", ok, err)
+		}
+		// never abandoned without a lease
+		time.Sleep(2 * testLease)
+		mustReserve(t, s, "u1", "k1", "h1", false)
+	})
+	t.Run("perUser", func(t *testing.T) {
+		s := newStore(t)
+		defer closeStore(s)
+		mustReserve(t, s, "u1", "k1", "h1", true)
+		mustReserve(t, s, "u2", "k1", "h1", true)
+	})
+}
+
+// testLease of reservations taken over in tests
+const testLease = 50 * time.Millisecond
+
+// mustReserve key without a lease, so reservations are never taken over
+func mustReserve(t *testing.T, s feedback.IdempotencyStore, user, key, hash string, wantOK bool) feedback.IdempotencyRecord {
+	t.Helper()
+	rec, ok, err := s.Reserve(context.Background(), user, key, hash, 0)
+	if err != nil {
+		t.Fatal("Reserve() error", err)
+	}
+	if ok != wantOK {
+		t.Fatalf("Reserve(%q, %q) reserved = %v want %v", user, key, ok, wantOK)
+	}
+	return rec
+}
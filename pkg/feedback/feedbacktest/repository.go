@@ -0,0 +1,478 @@
+// Package feedbacktest provides a conformance test suite for implementations
+// of the feedback.Repository interface.
+package feedbacktest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+)
+
+// Factory returns an empty Repository for a single test.
+// Repositories implementing io.Closer are closed once the test is done.
+type Factory func(t *testing.T) feedback.Repository
+
+// TestRepository runs the shared Repository behaviour tests against repositories created by newRepo
+func TestRepository(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		test func(*testing.T, Factory)
+	}{
+		{"duplicateEntry", testDuplicateEntry},
+		{"latestOrder", testLatestOrder},
+		{"latestLimit", testLatestLimit},
+		{"latestFiltered", testLatestFiltered},
+		{"latestInRange", testLatestInRange},
+		{"latestBefore", testLatestBefore},
+		{"bySession", testBySession},
+		{"update", testUpdate},
+		{"stats", testStats},
+		{"concurrentAdd", testConcurrentAdd},
+		{"concurrentDuplicate", testConcurrentDuplicate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepo)
+		})
+	}
+}
+
+func testDuplicateEntry(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	defer closeStore(repo)
+
+	mustAdd(t, repo, feedback.Entry{SessionID: "s1", UserID: "u1", Rating: 1})
+	mustAdd(t, repo, feedback.Entry{SessionID: "s1", UserID: "u2", Rating: 1})
+	mustAdd(t, repo, feedback.Entry{SessionID: "s2", UserID: "u1", Rating: 1})
+
+	err := repo.Add(context.Background(), feedback.Entry{SessionID: "s1", UserID: "u1", Rating: 5, Comment: "again"})
+	if err != feedback.ErrDuplicateEntry {
+		t.Fatalf("Add() = %v want %v", err, feedback.ErrDuplicateEntry)
+	}
+
+	entries, err := repo.GetLatest(context.Background(), 15)
+	if err != nil {
+		t.Fatal("GetLatest() error", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("GetLatest() returned %d entries want 3", len(entries))
+	}
+}
+
+func testLatestOrder(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	defer closeStore(repo)
+
+	for i := 0; i < 5; i++ {
+		mustAdd(t, repo, feedback.Entry{SessionID: fmt.Sprint("s", i), UserID: "u1", Rating: 3})
+	}
+
+	entries, err := repo.GetLatest(context.Background(), 15)
+	if err != nil {
+		t.Fatal("GetLatest() error", err)
+	}
+	assertSessions(t, entries, "s4", "s3", "s2", "s1", "s0")
+	for _, e := range entries {
+		if e.ID == "" {
+			t.Errorf("GetLatest() returned entry without id: %v", e)
+		}
+	}
+}
+
+func testLatestLimit(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	defer closeStore(repo)
+
+	entries, err := repo.GetLatest(context.Background(), 15)
+	if err != nil {
+		t.Fatal("GetLatest() error", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("GetLatest() on empty repository returned %v", entries)
+	}
+
+	for i := 0; i < 5; i++ {
+		mustAdd(t, repo, feedback.Entry{SessionID: fmt.Sprint("s", i), UserID: "u1", Rating: 3})
+	}
+
+	tests := []struct {
+		n    uint
+		want []string
+	}{
+		{0, nil},
+		{1, []string{"s4"}},
+		{3, []string{"s4", "s3", "s2"}},
+		{5, []string{"s4", "s3", "s2", "s1", "s0"}},
+		{100, []string{"s4", "s3", "s2", "s1", "s0"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.n), func(t *testing.T) {
+			entries, err := repo.GetLatest(context.Background(), tt.n)
+			if err != nil {
+				t.Fatal("GetLatest() error", err)
+			}
+			assertSessions(t, entries, tt.want...)
+		})
+	}
+}
+
+func testLatestFiltered(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	defer closeStore(repo)
+
+	ratings := []int8{1, 5, 1, 3, 1, 5}
+	for i, r := range ratings {
+		mustAdd(t, repo, feedback.Entry{SessionID: fmt.Sprint("s", i), UserID: "u1", Rating: r})
+	}
+
+	tests := []struct {
+		n      uint
+		filter int
+		want   []string
+	}{
+		{15, 1, []string{"s4", "s2", "s0"}},
+		{2, 1, []string{"s4", "s2"}},
+		{15, 5, []string{"s5", "s1"}},
+		{15, 3, []string{"s3"}},
+		{15, 2, nil},
+		{0, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d/%d", tt.filter, tt.n), func(t *testing.T) {
+			entries, err := repo.GetLatestFiltered(context.Background(), tt.n, tt.filter)
+			if err != nil {
+				t.Fatal("GetLatestFiltered() error", err)
+			}
+			assertSessions(t, entries, tt.want...)
+			for _, e := range entries {
+				if int(e.Rating) != tt.filter {
+					t.Errorf("GetLatestFiltered() returned entry with rating %d want %d", e.Rating, tt.filter)
+				}
+			}
+		})
+	}
+}
+
+func testLatestInRange(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	defer closeStore(repo)
+
+	ratings := []int8{1, 5, 1}
+	for i, r := range ratings {
+		mustAdd(t, repo, feedback.Entry{SessionID: fmt.Sprint("s", i), UserID: "u1", Rating: r})
+	}
+
+	entries, err := repo.GetLatest(context.Background(), 15)
+	if err != nil {
+		t.Fatal("GetLatest() error", err)
+	}
+	for _, e := range entries {
+		if e.CreatedAt.IsZero() {
+			t.Fatalf("GetLatest() returned entry without creation time: %v", e)
+		}
+	}
+
+	// stores may use their own clock, so the bounds leave some room for skew
+	now := time.Now()
+	tests := []struct {
+		name   string
+		filter int
+		r      feedback.Range
+		want   []string
+	}{
+		{"unbounded", 0, feedback.Range{}, []string{"s2", "s1", "s0"}},
+		{"since", 0, feedback.Range{Since: now.Add(-time.Hour)}, []string{"s2", "s1", "s0"}},
+		{"until", 0, feedback.Range{Until: now.Add(time.Hour)}, []string{"s2", "s1", "s0"}},
+		{"future", 0, feedback.Range{Since: now.Add(time.Hour)}, nil},
+		{"past", 0, feedback.Range{Until: now.Add(-time.Hour)}, nil},
+		{"filtered", 1, feedback.Range{Since: now.Add(-time.Hour), Until: now.Add(time.Hour)}, []string{"s2", "s0"}},
+		{"filteredFuture", 1, feedback.Range{Since: now.Add(time.Hour)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				entries []feedback.Entry
+				err     error
+			)
+			if tt.filter > 0 {
+				entries, err = repo.GetLatestFilteredInRange(context.Background(), 15, tt.filter, tt.r)
+			} else {
+				entries, err = repo.GetLatestInRange(context.Background(), 15, tt.r)
+			}
+			if err != nil {
+				t.Fatal("GetLatestInRange() error", err)
+			}
+			assertSessions(t, entries, tt.want...)
+		})
+	}
+}
+
+func testLatestBefore(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	defer closeStore(repo)
+
+	ratings := []int8{1, 5, 1, 3, 1}
+	for i, r := range ratings {
+		mustAdd(t, repo, feedback.Entry{SessionID: fmt.Sprint("s", i), UserID: "u1", Rating: r})
+	}
+
+	tests := []struct {
+		name   string
+		filter int
+		pages  [][]string
+	}{
+		{"all", 0, [][]string{{"s4", "s3"}, {"s2", "s1"}, {"s0"}}},
+		{"filtered", 1, [][]string{{"s4", "s2"}, {"s0"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before string
+			for _, want := range tt.pages {
+				var (
+					entries []feedback.Entry
+					err     error
+				)
+				if tt.filter > 0 {
+					entries, err = repo.GetLatestFilteredBefore(context.Background(), 2, tt.filter, before, feedback.Range{})
+				} else {
+					entries, err = repo.GetLatestBefore(context.Background(), 2, before, feedback.Range{})
+				}
+				if err != nil {
+					t.Fatal("GetLatestBefore() error", err)
+				}
+				assertSessions(t, entries, want...)
+				before = entries[len(entries)-1].ID
+			}
+			entries, err := repo.GetLatestBefore(context.Background(), 2, before, feedback.Range{})
+			if err != nil {
+				t.Fatal("GetLatestBefore() error", err)
+			}
+			assertSessions(t, entries)
+		})
+	}
+
+	if _, err := repo.GetLatestBefore(context.Background(), 2, "not an id", feedback.Range{}); err != feedback.ErrInvalidCursor {
+		t.Fatalf("GetLatestBefore() = %v want %v", err, feedback.ErrInvalidCursor)
+	}
+}
+
+func testBySession(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	defer closeStore(repo)
+
+	mustAdd(t, repo, feedback.Entry{SessionID: "s1", UserID: "u1", Rating: 1})
+	mustAdd(t, repo, feedback.Entry{SessionID: "s2", UserID: "u1", Rating: 2})
+	mustAdd(t, repo, feedback.Entry{SessionID: "s1", UserID: "u2", Rating: 3})
+
+	entries, err := repo.GetBySession(context.Background(), "s1")
+	if err != nil {
+		t.Fatal("GetBySession() error", err)
+	}
+	assertSessions(t, entries, "s1", "s1")
+	if entries[0].UserID != "u2" || entries[1].UserID != "u1" {
+		t.Fatalf("GetBySession() = %v want newest first", entries)
+	}
+
+	entries, err = repo.GetBySession(context.Background(), "unknown")
+	if err != nil {
+		t.Fatal("GetBySession() error", err)
+	}
+	assertSessions(t, entries)
+}
+
+func testUpdate(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	defer closeStore(repo)
+
+	if _, err := repo.Get(context.Background(), "s1", "u1"); err != feedback.ErrNotFound {
+		t.Fatalf("Get() = %v want %v", err, feedback.ErrNotFound)
+	}
+	if err := repo.Update(context.Background(), feedback.Entry{SessionID: "s1", UserID: "u1", Rating: 5}); err != feedback.ErrNotFound {
+		t.Fatalf("Update() = %v want %v", err, feedback.ErrNotFound)
+	}
+
+	mustAdd(t, repo, feedback.Entry{SessionID: "s1", UserID: "u1", Rating: 1, Comment: "typo"})
+	mustAdd(t, repo, feedback.Entry{SessionID: "s2", UserID: "u1", Rating: 2})
+
+	before, err := repo.Get(context.Background(), "s1", "u1")
+	if err != nil {
+		t.Fatal("Get() error", err)
+	}
+	if before.Rating != 1 || before.Comment != "typo" || before.UpdatedAt.IsZero() {
+		t.Fatalf("Get() = %v", before)
+	}
+
+	if err := repo.Update(context.Background(), feedback.Entry{SessionID: "s1", UserID: "u1", Rating: 5, Comment: "fixed"}); err != nil {
+		t.Fatal("Update() error", err)
+	}
+	after, err := repo.Get(context.Background(), "s1", "u1")
+	if err != nil {
+		t.Fatal("Get() error", err)
+	}
+	if after.Rating != 5 || after.Comment != "fixed" {
+		t.Fatalf("Get() after Update() = %v", after)
+	}
+	if after.ID != before.ID || !after.CreatedAt.Equal(before.CreatedAt) {
+		t.Fatalf("Update() changed identity: %v -> %v", before, after)
+	}
+	if after.UpdatedAt.Before(before.UpdatedAt) {
+		t.Fatalf("Update() moved updated time backwards: %v -> %v", before.UpdatedAt, after.UpdatedAt)
+	}
+
+	// updates do not change the order of entries
+	entries, err := repo.GetLatest(context.Background(), 15)
+	if err != nil {
+		t.Fatal("GetLatest() error", err)
+	}
+	assertSessions(t, entries, "s2", "s1")
+}
+
+func testStats(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	defer closeStore(repo)
+
+	stats, err := repo.GetStats(context.Background(), feedback.Range{}, false)
+	if err != nil {
+		t.Fatal("GetStats() error", err)
+	}
+	if stats.Count != 0 || stats.Average != 0 {
+		t.Fatalf("GetStats() on empty repository = %v", stats)
+	}
+
+	mustAdd(t, repo, feedback.Entry{SessionID: "s1", UserID: "u1", Rating: 1})
+	mustAdd(t, repo, feedback.Entry{SessionID: "s1", UserID: "u2", Rating: 3})
+	mustAdd(t, repo, feedback.Entry{SessionID: "s2", UserID: "u1", Rating: 5})
+	mustAdd(t, repo, feedback.Entry{SessionID: "s2", UserID: "u2", Rating: 5})
+
+	stats, err = repo.GetStats(context.Background(), feedback.Range{}, false)
+	if err != nil {
+		t.Fatal("GetStats() error", err)
+	}
+	want := feedback.Summary{
+		Count:     4,
+		Average:   3.5,
+		Median:    4,
+		Histogram: map[int8]int{1: 1, 2: 0, 3: 1, 4: 0, 5: 2},
+	}
+	if !reflect.DeepEqual(stats.Summary, want) || stats.Sessions != nil {
+		t.Fatalf("GetStats() = %v want %v", stats, want)
+	}
+
+	stats, err = repo.GetStats(context.Background(), feedback.Range{}, true)
+	if err != nil {
+		t.Fatal("GetStats() error", err)
+	}
+	if len(stats.Sessions) != 2 || stats.Sessions["s1"].Average != 2 || stats.Sessions["s2"].Median != 5 {
+		t.Fatalf("GetStats() by session = %v", stats.Sessions)
+	}
+
+	stats, err = repo.GetStats(context.Background(), feedback.Range{Since: time.Now().Add(time.Hour)}, true)
+	if err != nil {
+		t.Fatal("GetStats() error", err)
+	}
+	if stats.Count != 0 || len(stats.Sessions) != 0 {
+		t.Fatalf("GetStats() in future = %v", stats)
+	}
+}
+
+func testConcurrentAdd(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	defer closeStore(repo)
+
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- repo.Add(context.Background(), feedback.Entry{SessionID: "s1", UserID: fmt.Sprint("u", i), Rating: 4})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error("concurrent Add() error", err)
+		}
+	}
+
+	entries, err := repo.GetLatest(context.Background(), writers*2)
+	if err != nil {
+		t.Fatal("GetLatest() error", err)
+	}
+	if len(entries) != writers {
+		t.Fatalf("GetLatest() returned %d entries want %d", len(entries), writers)
+	}
+	users := make(map[string]bool)
+	for _, e := range entries {
+		users[e.UserID] = true
+	}
+	if len(users) != writers {
+		t.Fatalf("GetLatest() returned %d distinct users want %d", len(users), writers)
+	}
+}
+
+func testConcurrentDuplicate(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	defer closeStore(repo)
+
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- repo.Add(context.Background(), feedback.Entry{SessionID: "s1", UserID: "u1", Rating: 4})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var added int
+	for err := range errs {
+		switch err {
+		case nil:
+			added++
+		case feedback.ErrDuplicateEntry:
+		default:
+			t.Error("concurrent Add() error", err)
+		}
+	}
+	if added != 1 {
+		t.Fatalf("concurrent Add() succeeded %d times want 1", added)
+	}
+}
+
+// closeStore releases the resources held by s, like database connections
+func closeStore(s interface{}) {
+	if c, ok := s.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+func mustAdd(t *testing.T, repo feedback.Repository, e feedback.Entry) {
+	t.Helper()
+	if err := repo.Add(context.Background(), e); err != nil {
+		t.Fatalf("Add(%v) error: %v", e, err)
+	}
+}
+
+func assertSessions(t *testing.T, entries []feedback.Entry, want ...string) {
+	t.Helper()
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries %v want sessions %v", len(entries), entries, want)
+	}
+	for i, e := range entries {
+		if e.SessionID != want[i] {
+			t.Fatalf("entry %d has session %q want %q (got %v)", i, e.SessionID, want[i], entries)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package feedbacktest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+)
+
+// SessionFactory returns an empty SessionStore for a single test, closed afterwards if it is an io.Closer
+type SessionFactory func(t *testing.T) feedback.SessionStore
+
+// TestSessionStore runs the shared SessionStore behaviour tests against stores created by newStore
+func TestSessionStore(t *testing.T, newStore SessionFactory) {
+	ctx := context.Background()
+	start := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	running := feedback.Session{
+		ID:           "s1",
+		Participants: []string{"u1", "u2"},
+		StartedAt:    start,
+		RatingWindow: time.Hour,
+	}
+	ended := running
+	ended.Participants = []string{"u2", "u3"}
+	ended.EndedAt = start.Add(30 * time.Minute)
+
+	t.Run("unknown", func(t *testing.T) {
+		s := newStore(t)
+		defer closeStore(s)
+		if _, err := s.GetSession(ctx, "s1"); err != feedback.ErrUnknownSession {
+			t.Fatalf("GetSession() error = %v want %v", err, feedback.ErrUnknownSession)
+		}
+		if _, _, err := s.GetParticipation(ctx, "s1", "u1"); err != feedback.ErrUnknownSession {
+			t.Fatalf("GetParticipation() error = %v want %v", err, feedback.ErrUnknownSession)
+		}
+	})
+	t.Run("replace", func(t *testing.T) {
+		s := newStore(t)
+		defer closeStore(s)
+		for _, session := range []feedback.Session{running, ended} {
+			if err := s.PutSession(ctx, session); err != nil {
+				t.Fatal("PutSession() error", err)
+			}
+			got, err := s.GetSession(ctx, session.ID)
+			if err != nil {
+				t.Fatal("GetSession() error", err)
+			}
+			assertSession(t, got, session)
+		}
+	})
+	t.Run("participation", func(t *testing.T) {
+		s := newStore(t)
+		defer closeStore(s)
+		if err := s.PutSession(ctx, running); err != nil {
+			t.Fatal("PutSession() error", err)
+		}
+		if err := s.PutSession(ctx, ended); err != nil {
+			t.Fatal("PutSession() error", err)
+		}
+		for user, want := range map[string]bool{"u1": false, "u2": true, "u3": true, "u4": false} {
+			got, participant, err := s.GetParticipation(ctx, "s1", user)
+			if err != nil {
+				t.Fatal("GetParticipation() error", err)
+			}
+			if participant != want {
+				t.Errorf("GetParticipation(%q) participant = %v want %v", user, participant, want)
+			}
+			withoutParticipants := ended
+			withoutParticipants.Participants = nil
+			assertSession(t, got, withoutParticipants)
+		}
+	})
+}
+
+// assertSession compares sessions, ignoring the location of their times
+func assertSession(t *testing.T, got, want feedback.Session) {
+	t.Helper()
+	if !got.StartedAt.Equal(want.StartedAt) || !got.EndedAt.Equal(want.EndedAt) {
+		t.Errorf("session times = %v - %v want %v - %v", got.StartedAt, got.EndedAt, want.StartedAt, want.EndedAt)
+	}
+	got.StartedAt, got.EndedAt = want.StartedAt, want.EndedAt
+	if len(got.Participants) == 0 && len(want.Participants) == 0 {
+		got.Participants = want.Participants
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("session = %+v want %+v", got, want)
+	}
+}
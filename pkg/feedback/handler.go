@@ -1,9 +1,13 @@
 package feedback
 
 import (
+	"context"
 	"encoding/json"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
@@ -13,25 +17,45 @@ import (
 // Handler for the service endpoints
 func (s *Service) Handler() *mux.Router {
 	m := mux.NewRouter()
-	m.Path("/list").Methods("GET").HandlerFunc(s.MakeHandler(s.getEntries))
-	m.Path("/{sessionID}").Methods("POST").HandlerFunc(s.MakeHandler(s.addEntry))
+	if s.Sessions != nil {
+		m.Path("/sessions/{sessionID}").Methods("PUT").HandlerFunc(s.MakeHandler(s.authorize(PermManageSessions, s.registerSession)))
+		m.Path("/sessions/{sessionID}").Methods("GET").HandlerFunc(s.MakeHandler(s.authorize(PermManageSessions, s.getRegisteredSession)))
+	}
+	m.Path("/list").Methods("GET").HandlerFunc(s.MakeHandler(s.authorize(PermRead, s.getEntries)))
+	m.Path("/stats").Methods("GET").HandlerFunc(s.MakeHandler(s.authorize(PermRead, s.getStats)))
+	m.Path("/{sessionID}").Methods("GET").HandlerFunc(s.MakeHandler(s.authorize(PermRead, s.getSession)))
+	m.Path("/{sessionID}").Methods("POST").HandlerFunc(s.MakeHandler(s.authorize(PermSubmit, s.idempotent(s.limit(s.addEntry)))))
+	m.Path("/{sessionID}").Methods("PUT").HandlerFunc(s.MakeHandler(s.authorize(PermSubmit, s.limit(s.updateEntry))))
+	m.Path("/{sessionID}/me").Methods("GET").HandlerFunc(s.MakeHandler(s.authorize(PermSubmit, s.getOwnEntry)))
 	return m
 }
 
+// maxBodySize of requests containing an entry
+const maxBodySize = 4 << 10
+
 type handler func(http.ResponseWriter, *http.Request) error
 
-// MakeHandler with logging
+// MakeHandler with logging, metrics and tracing
 func (s *Service) MakeHandler(h handler) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return instrument(trace(func(w http.ResponseWriter, r *http.Request) {
+		if s.Timeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
 		err := h(w, r)
 		if err != nil {
+			traceError(r.Context(), err)
+		}
+		// requests cancelled by the client or timing out are only logged as failed, see deferError
+		if err != nil && publicError(err) == ErrInternal && r.Context().Err() == nil {
 			s.Error("request error", zap.Error(err))
 		}
-	}
+	}))
 }
 
 func (s *Service) getEntries(w http.ResponseWriter, r *http.Request) (err error) {
-	defer func() { s.deferError(w, err) }()
+	defer func() { s.deferError(w, r, err) }()
 	var entries []Entry
 
 	limit := uint(15)
@@ -39,16 +63,31 @@ func (s *Service) getEntries(w http.ResponseWriter, r *http.Request) (err error)
 	if len(limitParam) > 0 {
 		u64, err := strconv.ParseUint(limitParam, 10, 32)
 		if err != nil {
-			return errors.Wrap(err, "invalid limit value")
+			return ErrInvalidLimit.Wrap(err)
+		}
+		if u64 == 0 {
+			return ErrInvalidLimit
 		}
 		limit = uint(u64)
 	}
 
+	rng, err := parseRange(r)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := r.URL.Query()["cursor"]; ok {
+		return s.getPage(w, r, limit, rng)
+	}
+
 	filter := r.URL.Query().Get("filter")
-	if len(filter) > 0 {
-		entries, err = s.getFiltered(limit, filter)
-	} else {
-		entries, err = s.GetLatest(limit)
+	switch {
+	case len(filter) > 0:
+		entries, err = s.getFiltered(r.Context(), limit, filter, rng)
+	case !rng.IsZero():
+		entries, err = s.GetLatestInRange(r.Context(), limit, rng)
+	default:
+		entries, err = s.GetLatest(r.Context(), limit)
 	}
 	if err != nil {
 		return err
@@ -56,57 +95,201 @@ func (s *Service) getEntries(w http.ResponseWriter, r *http.Request) (err error)
 	if entries == nil {
 		entries = []Entry{}
 	}
-	return writeJSON(w, entries)
+	return writeJSON(w, s.redact(r, entries))
 }
 
-func (s *Service) getFiltered(limit uint, filter string) (entries []Entry, err error) {
+func (s *Service) getFiltered(ctx context.Context, limit uint, filter string, rng Range) (entries []Entry, err error) {
 	f, err := strconv.Atoi(filter)
 	if err != nil {
-		return nil, errors.Wrap(err, "invalid filter value")
+		return nil, ErrInvalidFilter.Wrap(err)
+	}
+	if !rng.IsZero() {
+		entries, err = s.GetLatestFilteredInRange(ctx, limit, f, rng)
+	} else {
+		entries, err = s.GetLatestFiltered(ctx, limit, f)
 	}
-	entries, err = s.GetLatestFiltered(limit, f)
 	if err != nil {
 		return nil, err
 	}
 	return entries, err
 }
 
+func (s *Service) getPage(w http.ResponseWriter, r *http.Request, limit uint, rng Range) (err error) {
+	var page Page
+	cursor := r.URL.Query().Get("cursor")
+	filter := r.URL.Query().Get("filter")
+	if len(filter) > 0 {
+		var f int
+		f, err = strconv.Atoi(filter)
+		if err != nil {
+			return ErrInvalidFilter.Wrap(err)
+		}
+		page, err = s.GetPageFiltered(r.Context(), limit, f, cursor, rng)
+	} else {
+		page, err = s.GetPage(r.Context(), limit, cursor, rng)
+	}
+	if err != nil {
+		return err
+	}
+	page.Entries = s.redact(r, page.Entries)
+	return writeJSON(w, page)
+}
+
+func (s *Service) getStats(w http.ResponseWriter, r *http.Request) (err error) {
+	defer func() { s.deferError(w, r, err) }()
+
+	rng, err := parseRange(r)
+	if err != nil {
+		return err
+	}
+
+	var bySession bool
+	switch r.URL.Query().Get("groupBy") {
+	case "":
+	case "session":
+		bySession = true
+	default:
+		return ErrInvalidGroupBy
+	}
+
+	stats, err := s.GetStats(r.Context(), rng, bySession)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, stats)
+}
+
+func parseRange(r *http.Request) (rng Range, err error) {
+	if since := r.URL.Query().Get("since"); len(since) > 0 {
+		rng.Since, err = time.Parse(time.RFC3339, since)
+		if err != nil {
+			return rng, ErrInvalidSince.Wrap(err)
+		}
+	}
+	if until := r.URL.Query().Get("until"); len(until) > 0 {
+		rng.Until, err = time.Parse(time.RFC3339, until)
+		if err != nil {
+			return rng, ErrInvalidUntil.Wrap(err)
+		}
+	}
+	return rng, nil
+}
+
+func (s *Service) getSession(w http.ResponseWriter, r *http.Request) (err error) {
+	defer func() { s.deferError(w, r, err) }()
+
+	session, err := s.GetBySession(r.Context(), mux.Vars(r)["sessionID"])
+	if err != nil {
+		return err
+	}
+	session.Entries = s.redact(r, session.Entries)
+	return writeJSON(w, session)
+}
+
 func (s *Service) addEntry(w http.ResponseWriter, r *http.Request) (err error) {
-	defer func() { s.deferError(w, err) }()
+	defer func() { s.deferError(w, r, err) }()
 
-	var entry Entry
-	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+	entry, err := s.readEntry(r)
+	if err != nil {
 		return err
 	}
 
-	vars := mux.Vars(r)
-	if len(vars["sessionID"]) < 1 {
-		return ErrNoSession
+	if err := s.Add(r.Context(), entry); err != nil {
+		return err
 	}
 
-	entry.SessionID = vars["sessionID"]
-	entry.UserID = r.Header.Get("Ubi-UserId")
+	return writeJSON(w, struct{}{})
+}
 
-	if len(entry.UserID) < 1 {
-		return ErrNoUserID
+func (s *Service) updateEntry(w http.ResponseWriter, r *http.Request) (err error) {
+	defer func() { s.deferError(w, r, err) }()
+
+	entry, err := s.readEntry(r)
+	if err != nil {
+		return err
 	}
 
-	if err := s.Add(entry); err != nil {
+	if err := s.Update(r.Context(), entry); err != nil {
 		return err
 	}
 
 	return writeJSON(w, struct{}{})
 }
 
-func (s *Service) deferError(w http.ResponseWriter, err error) {
+func (s *Service) getOwnEntry(w http.ResponseWriter, r *http.Request) (err error) {
+	defer func() { s.deferError(w, r, err) }()
+
+	id, err := s.identity(r)
+	if err != nil {
+		return err
+	}
+	entry, err := s.Get(r.Context(), mux.Vars(r)["sessionID"], id.UserID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, entry)
+}
+
+// readEntry from the request body, taking the session from the path and the user from its verified identity
+func (s *Service) readEntry(r *http.Request) (entry Entry, err error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		return entry, ErrInvalidBody.Wrap(err)
+	}
+	if len(body) > maxBodySize {
+		return entry, ErrBodyTooLarge
+	}
+
+	v := &ValidationError{}
+	entry, err = decodeEntry(body, v)
+	if err != nil {
+		return entry, err
+	}
+
+	vars := mux.Vars(r)
+	if len(vars["sessionID"]) < 1 {
+		return entry, ErrNoSession
+	}
+
+	entry.SessionID = vars["sessionID"]
+	id, err := s.identity(r)
+	if err != nil {
+		return entry, err
+	}
+	entry.UserID = id.UserID
+	if len(entry.UserID) < 1 {
+		return entry, ErrNoUserID
+	}
+
+	// report all violations at once instead of only the ones found while decoding
+	if len(v.Fields) > 0 {
+		validateEntry(entry, v)
+		return entry, v
+	}
+	return entry, nil
+}
+
+func (s *Service) deferError(w http.ResponseWriter, r *http.Request, err error) {
 	if err != nil {
-		s.Warn("failed adding entry", zap.Error(err))
+		err = contextError(r.Context(), err)
+		s.Warn("request failed", zap.Error(err))
+		if publicError(err).Status == http.StatusServiceUnavailable && s.RetryAfter > 0 {
+			w.Header().Set("Retry-After", ceilSeconds(s.RetryAfter))
+		}
 		if err := writeError(w, err); err != nil {
 			s.Error("write error", zap.Error(err))
 		}
 	}
 }
 
+// contextError replaces errors caused by running out of time with ErrTimeout
+func contextError(ctx context.Context, err error) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrTimeout.Wrap(err)
+	}
+	return err
+}
+
 func writeJSON(w http.ResponseWriter, v interface{}) error {
 	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
@@ -118,15 +301,25 @@ func writeJSON(w http.ResponseWriter, v interface{}) error {
 }
 
 type errorResponse struct {
-	Err string `json:"error"`
+	Err    string       `json:"error"`
+	Code   string       `json:"code"`
+	Fields []FieldError `json:"fields,omitempty"`
 }
 
+// writeError responding with the public part of e, hiding internal errors behind ErrInternal
 func writeError(w http.ResponseWriter, e error) error {
-	data, err := json.Marshal(errorResponse{e.Error()})
+	pub := publicError(e)
+	resp := errorResponse{Err: pub.Message, Code: pub.Code}
+	if v, ok := errors.Cause(e).(*ValidationError); ok {
+		resp.Fields = v.Fields
+	}
+	data, err := json.Marshal(resp)
 	if err != nil {
 		return err
 	}
 	w.Header().Set("content-type", "application/json; charset=utf-8")
-	http.Error(w, string(data), http.StatusInternalServerError)
-	return nil
+	w.Header().Set("x-content-type-options", "nosniff")
+	w.WriteHeader(pub.Status)
+	_, err = w.Write(data)
+	return err
 }
@@ -1,15 +1,19 @@
 package feedback
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/bborbe/http/requestbuilder"
 	"github.com/gorilla/mux"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/ratelimit"
 
 	"github.com/playnet-public/libs/log"
 )
@@ -62,18 +66,18 @@ func TestService_getEntries(t *testing.T) {
 		{
 			"filteredList",
 			[]Entry{
-				{"1", "1", "1", 1, ""},
-				{"3", "3", "1", 1, ""},
-				{"5", "5", "1", 1, ""},
+				{ID: "1", SessionID: "1", UserID: "1", Rating: 1},
+				{ID: "3", SessionID: "3", UserID: "1", Rating: 1},
+				{ID: "5", SessionID: "5", UserID: "1", Rating: 1},
 			},
 			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/list").
 				SetMethod("GET").AddParameter("filter", "1"),
 			nil,
 			func(n uint, f int) ([]Entry, error) {
 				return []Entry{
-					{"1", "1", "1", 1, ""},
-					{"3", "3", "1", 1, ""},
-					{"5", "5", "1", 1, ""},
+					{ID: "1", SessionID: "1", UserID: "1", Rating: 1},
+					{ID: "3", SessionID: "3", UserID: "1", Rating: 1},
+					{ID: "5", SessionID: "5", UserID: "1", Rating: 1},
 				}, nil
 			},
 			false,
@@ -81,14 +85,14 @@ func TestService_getEntries(t *testing.T) {
 		{
 			"customLimitList",
 			[]Entry{
-				{"1", "1", "1", 1, ""},
+				{ID: "1", SessionID: "1", UserID: "1", Rating: 1},
 			},
 			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/list").
 				SetMethod("GET").AddParameter("filter", "1").AddParameter("limit", "1"),
 			nil,
 			func(n uint, f int) ([]Entry, error) {
 				return []Entry{
-					{"1", "1", "1", 1, ""},
+					{ID: "1", SessionID: "1", UserID: "1", Rating: 1},
 				}, nil
 			},
 			false,
@@ -101,9 +105,9 @@ func TestService_getEntries(t *testing.T) {
 			nil,
 			func(n uint, f int) ([]Entry, error) {
 				return []Entry{
-					{"1", "1", "1", 1, ""},
-					{"3", "3", "1", 1, ""},
-					{"5", "5", "1", 1, ""},
+					{ID: "1", SessionID: "1", UserID: "1", Rating: 1},
+					{ID: "3", SessionID: "3", UserID: "1", Rating: 1},
+					{ID: "5", SessionID: "5", UserID: "1", Rating: 1},
 				}, nil
 			},
 			true,
@@ -127,7 +131,7 @@ func TestService_getEntries(t *testing.T) {
 			nil,
 			func(n uint, f int) ([]Entry, error) {
 				return []Entry{
-					{"1", "1", "1", 1, ""},
+					{ID: "1", SessionID: "1", UserID: "1", Rating: 1},
 				}, nil
 			},
 			true,
@@ -270,3 +274,1127 @@ func TestService_addEntry(t *testing.T) {
 		})
 	}
 }
+
+func TestService_getEntriesInRange(t *testing.T) {
+	svc := New(log.NewNop(), nil)
+	since := time.Date(2018, 3, 17, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		request    requestbuilder.HttpRequestBuilder
+		wantRange  Range
+		wantFilter int
+		wantErr    bool
+	}{
+		{
+			"since",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/list").
+				SetMethod("GET").AddParameter("since", "2018-03-17T20:00:00Z"),
+			Range{Since: since},
+			0,
+			false,
+		},
+		{
+			"filteredUntil",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/list").
+				SetMethod("GET").AddParameter("filter", "2").AddParameter("until", "2018-03-17T20:00:00Z"),
+			Range{Until: since},
+			2,
+			false,
+		},
+		{
+			"invalidSince",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/list").
+				SetMethod("GET").AddParameter("since", "yesterday"),
+			Range{},
+			0,
+			true,
+		},
+		{
+			"invalidUntil",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/list").
+				SetMethod("GET").AddParameter("until", "1521316800"),
+			Range{},
+			0,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				gotRange  Range
+				gotFilter int
+			)
+			repo := newMockRepository(nil, nil, nil)
+			repo.getLatestInRange = func(n uint, r Range) ([]Entry, error) {
+				gotRange = r
+				return []Entry{}, nil
+			}
+			repo.getLatestFilteredInRange = func(n uint, f int, r Range) ([]Entry, error) {
+				gotRange, gotFilter = r, f
+				return []Entry{}, nil
+			}
+			svc.repo = repo
+
+			req, err := tt.request.Build()
+			if err != nil {
+				t.Error(err)
+			}
+			w := httptest.NewRecorder()
+			if err := svc.getEntries(w, req); (err != nil) != tt.wantErr {
+				t.Fatalf("Service.getEntries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !gotRange.Since.Equal(tt.wantRange.Since) || !gotRange.Until.Equal(tt.wantRange.Until) {
+				t.Errorf("Service.getEntries() range = %v, want %v", gotRange, tt.wantRange)
+			}
+			if gotFilter != tt.wantFilter {
+				t.Errorf("Service.getEntries() filter = %v, want %v", gotFilter, tt.wantFilter)
+			}
+		})
+	}
+}
+
+func TestService_getEntriesPage(t *testing.T) {
+	svc := New(log.NewNop(), nil)
+
+	tests := []struct {
+		name     string
+		request  requestbuilder.HttpRequestBuilder
+		wantPage Page
+		wantErr  bool
+	}{
+		{
+			"firstPage",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/list").
+				SetMethod("GET").AddParameter("cursor", "").AddParameter("limit", "1"),
+			Page{
+				Entries: []Entry{{ID: "3", Rating: 1}},
+				Next:    encodeCursor("3"),
+			},
+			false,
+		},
+		{
+			"filteredPage",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/list").
+				SetMethod("GET").AddParameter("cursor", encodeCursor("3")).AddParameter("filter", "1"),
+			Page{
+				Entries: []Entry{{ID: "1", Rating: 1}},
+			},
+			false,
+		},
+		{
+			"invalidCursor",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/list").
+				SetMethod("GET").AddParameter("cursor", "abc"),
+			Page{},
+			true,
+		},
+		{
+			"invalidFilter",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/list").
+				SetMethod("GET").AddParameter("cursor", "").AddParameter("filter", "abc"),
+			Page{},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMockRepository(nil, nil, nil)
+			repo.getLatestBefore = func(n uint, before string, r Range) ([]Entry, error) {
+				return []Entry{{ID: "3", Rating: 1}, {ID: "2", Rating: 2}}, nil
+			}
+			repo.getLatestFilteredBefore = func(n uint, f int, before string, r Range) ([]Entry, error) {
+				if before != "3" {
+					t.Errorf("Service.getEntries() before = %q want %q", before, "3")
+				}
+				return []Entry{{ID: "1", Rating: 1}}, nil
+			}
+			svc.repo = repo
+
+			req, err := tt.request.Build()
+			if err != nil {
+				t.Error(err)
+			}
+			w := httptest.NewRecorder()
+			if err := svc.getEntries(w, req); (err != nil) != tt.wantErr {
+				t.Fatalf("Service.getEntries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var page Page
+			if err := json.NewDecoder(w.Result().Body).Decode(&page); err != nil {
+				t.Fatal("failed to decode response", err)
+			}
+			if !reflect.DeepEqual(page, tt.wantPage) {
+				t.Errorf("Service.getEntries() got = %v, want %v", page, tt.wantPage)
+			}
+		})
+	}
+}
+
+func TestService_getSession(t *testing.T) {
+	svc := New(log.NewNop(), nil)
+
+	tests := []struct {
+		name             string
+		request          requestbuilder.HttpRequestBuilder
+		getBySessionFunc func(string) ([]Entry, error)
+		want             SessionFeedback
+		wantErr          bool
+	}{
+		{
+			"session",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/1").SetMethod("GET"),
+			func(sessionID string) ([]Entry, error) {
+				return []Entry{
+					{ID: "2", SessionID: sessionID, UserID: "2", Rating: 4},
+					{ID: "1", SessionID: sessionID, UserID: "1", Rating: 2},
+				}, nil
+			},
+			SessionFeedback{
+				SessionID: "1",
+				Entries: []Entry{
+					{ID: "2", SessionID: "1", UserID: "2", Rating: 4},
+					{ID: "1", SessionID: "1", UserID: "1", Rating: 2},
+				},
+				Summary: Summary{
+					Count:     2,
+					Average:   3,
+					Median:    3,
+					Histogram: map[int8]int{1: 0, 2: 1, 3: 0, 4: 1, 5: 0},
+				},
+			},
+			false,
+		},
+		{
+			"error",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/1").SetMethod("GET"),
+			func(sessionID string) ([]Entry, error) {
+				return nil, errors.New("test error")
+			},
+			SessionFeedback{},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMockRepository(nil, nil, nil)
+			repo.getBySession = tt.getBySessionFunc
+			svc.repo = repo
+
+			req, err := tt.request.Build()
+			if err != nil {
+				t.Error(err)
+			}
+			w := httptest.NewRecorder()
+			svc.Handler().ServeHTTP(w, req)
+
+			if tt.wantErr {
+				retErr := errorResponse{}
+				if err := json.NewDecoder(w.Result().Body).Decode(&retErr); err != nil || retErr.Err == "" {
+					t.Fatal("Service.getSession() wanted err", retErr, err)
+				}
+				return
+			}
+			var got SessionFeedback
+			if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+				t.Fatal("failed to decode response", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Service.getSession() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestService_updateEntry(t *testing.T) {
+	svc := New(log.NewNop(), nil)
+	tests := []struct {
+		name       string
+		request    requestbuilder.HttpRequestBuilder
+		updateFunc func(Entry) error
+		wantErr    bool
+	}{
+		{
+			"update",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/0").
+				SetMethod("PUT").SetBody(strings.NewReader(`{
+					"rating": 5,
+					"comment": "fixed"
+				}`)).AddHeader("Ubi-UserId", "1"),
+			func(e Entry) error {
+				if e.SessionID != "0" || e.UserID != "1" || e.Rating != 5 || e.Comment != "fixed" {
+					return errors.New("unexpected entry")
+				}
+				return nil
+			},
+			false,
+		},
+		{
+			"invalidRating",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/0").
+				SetMethod("PUT").SetBody(strings.NewReader(`{
+					"rating": 9
+				}`)).AddHeader("Ubi-UserId", "1"),
+			nil,
+			true,
+		},
+		{
+			"noUserID",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/0").
+				SetMethod("PUT").SetBody(strings.NewReader(`{
+					"rating": 1
+				}`)),
+			nil,
+			true,
+		},
+		{
+			"notFound",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/0").
+				SetMethod("PUT").SetBody(strings.NewReader(`{
+					"rating": 1
+				}`)).AddHeader("Ubi-UserId", "1"),
+			func(e Entry) error {
+				return ErrNotFound
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMockRepository(nil, nil, nil)
+			repo.update = tt.updateFunc
+			svc.repo = repo
+
+			req, err := tt.request.Build()
+			if err != nil {
+				t.Error(err)
+			}
+			w := httptest.NewRecorder()
+			svc.Handler().ServeHTTP(w, req)
+
+			retErr := errorResponse{}
+			if err := json.NewDecoder(w.Result().Body).Decode(&retErr); err != nil {
+				t.Error("failed to decode response", err)
+			}
+			if (retErr.Err == "") == tt.wantErr {
+				t.Error("Service.updateEntry() wanted err", retErr)
+			}
+		})
+	}
+}
+
+func TestService_getOwnEntry(t *testing.T) {
+	svc := New(log.NewNop(), nil)
+	repo := newMockRepository(nil, nil, nil)
+	repo.get = func(sessionID, userID string) (Entry, error) {
+		if sessionID == "0" && userID == "1" {
+			return Entry{ID: "1", SessionID: "0", UserID: "1", Rating: 3}, nil
+		}
+		return Entry{}, ErrNotFound
+	}
+	svc.repo = repo
+
+	req, err := requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/0/me").
+		SetMethod("GET").AddHeader("Ubi-UserId", "1").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	svc.Handler().ServeHTTP(w, req)
+	var got Entry
+	if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+		t.Fatal("failed to decode response", err)
+	}
+	if want := (Entry{ID: "1", SessionID: "0", UserID: "1", Rating: 3}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Service.getOwnEntry() got = %v, want %v", got, want)
+	}
+
+	req, err = requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/0/me").
+		SetMethod("GET").AddHeader("Ubi-UserId", "2").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	svc.Handler().ServeHTTP(w, req)
+	retErr := errorResponse{}
+	if err := json.NewDecoder(w.Result().Body).Decode(&retErr); err != nil || retErr.Err == "" {
+		t.Error("Service.getOwnEntry() wanted err", retErr, err)
+	}
+}
+
+func TestService_getStats(t *testing.T) {
+	svc := New(log.NewNop(), nil)
+
+	tests := []struct {
+		name          string
+		request       requestbuilder.HttpRequestBuilder
+		wantBySession bool
+		wantErr       bool
+	}{
+		{
+			"total",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/stats").SetMethod("GET"),
+			false,
+			false,
+		},
+		{
+			"bySession",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/stats").
+				SetMethod("GET").AddParameter("groupBy", "session").AddParameter("since", "2018-03-17T20:00:00Z"),
+			true,
+			false,
+		},
+		{
+			"invalidGroupBy",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/stats").
+				SetMethod("GET").AddParameter("groupBy", "user"),
+			false,
+			true,
+		},
+		{
+			"invalidSince",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/stats").
+				SetMethod("GET").AddParameter("since", "x"),
+			false,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotBySession bool
+			repo := newMockRepository(nil, nil, nil)
+			repo.getStats = func(r Range, bySession bool) (Stats, error) {
+				gotBySession = bySession
+				var sessions map[string]map[int8]int
+				if bySession {
+					sessions = map[string]map[int8]int{"1": {4: 1}}
+				}
+				return NewStats(map[int8]int{4: 1}, sessions), nil
+			}
+			svc.repo = repo
+
+			req, err := tt.request.Build()
+			if err != nil {
+				t.Error(err)
+			}
+			w := httptest.NewRecorder()
+			svc.Handler().ServeHTTP(w, req)
+
+			if tt.wantErr {
+				retErr := errorResponse{}
+				if err := json.NewDecoder(w.Result().Body).Decode(&retErr); err != nil || retErr.Err == "" {
+					t.Fatal("Service.getStats() wanted err", retErr, err)
+				}
+				return
+			}
+			var got Stats
+			if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+				t.Fatal("failed to decode response", err)
+			}
+			if gotBySession != tt.wantBySession {
+				t.Errorf("Service.getStats() bySession = %v, want %v", gotBySession, tt.wantBySession)
+			}
+			if got.Count != 1 || got.Average != 4 || (got.Sessions != nil) != tt.wantBySession {
+				t.Errorf("Service.getStats() got = %v", got)
+			}
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantMsg    string
+	}{
+		{"invalidRating", ErrInvalidRating, http.StatusBadRequest, "invalid_rating", ErrInvalidRating.Message},
+		{"duplicate", ErrDuplicateEntry, http.StatusConflict, "duplicate_entry", ErrDuplicateEntry.Message},
+		{"notFound", ErrNotFound, http.StatusNotFound, "not_found", ErrNotFound.Message},
+		{"wrapped", ErrInvalidLimit.Wrap(errors.New("strconv.ParseUint: parsing \"x\"")), http.StatusBadRequest, "invalid_limit", ErrInvalidLimit.Message},
+		{"internal", errors.New("pq: password authentication failed"), http.StatusInternalServerError, "internal", ErrInternal.Message},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if err := writeError(w, tt.err); err != nil {
+				t.Fatal("writeError() error", err)
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("writeError() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if ct := w.Header().Get("content-type"); ct != "application/json; charset=utf-8" {
+				t.Errorf("writeError() content-type = %q", ct)
+			}
+			var got errorResponse
+			if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+				t.Fatal("failed to decode response", err)
+			}
+			if got.Code != tt.wantCode || got.Err != tt.wantMsg {
+				t.Errorf("writeError() = %v, want %v/%v", got, tt.wantCode, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestService_HandlerStatus(t *testing.T) {
+	svc := New(log.NewNop(), nil)
+	repo := newMockRepository(func(e Entry) error {
+		return ErrDuplicateEntry
+	}, nil, nil)
+	svc.repo = repo
+
+	tests := []struct {
+		name       string
+		request    requestbuilder.HttpRequestBuilder
+		wantStatus int
+	}{
+		{
+			"list",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/list").SetMethod("GET"),
+			http.StatusOK,
+		},
+		{
+			"invalidLimit",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/list").
+				SetMethod("GET").AddParameter("limit", "-1"),
+			http.StatusBadRequest,
+		},
+		{
+			"zeroLimit",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/list").
+				SetMethod("GET").AddParameter("limit", "0"),
+			http.StatusBadRequest,
+		},
+		{
+			"invalidFilter",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/list").
+				SetMethod("GET").AddParameter("filter", "a"),
+			http.StatusBadRequest,
+		},
+		{
+			"malformedJSON",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/0").
+				SetMethod("POST").SetBody(strings.NewReader(`{"rating"`)).AddHeader("Ubi-UserId", "1"),
+			http.StatusBadRequest,
+		},
+		{
+			"noUserID",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/0").
+				SetMethod("POST").SetBody(strings.NewReader(`{"rating": 1}`)),
+			http.StatusBadRequest,
+		},
+		{
+			"invalidRating",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/0").
+				SetMethod("POST").SetBody(strings.NewReader(`{"rating": 6}`)).AddHeader("Ubi-UserId", "1"),
+			http.StatusBadRequest,
+		},
+		{
+			"duplicate",
+			requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/0").
+				SetMethod("POST").SetBody(strings.NewReader(`{"rating": 1}`)).AddHeader("Ubi-UserId", "1"),
+			http.StatusConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := tt.request.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := httptest.NewRecorder()
+			svc.Handler().ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("Service.Handler() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestService_addEntryValidation(t *testing.T) {
+	svc := New(log.NewNop(), newMockRepository(nil, nil, nil))
+
+	req, err := requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/session%201").
+		SetMethod("POST").SetBody(strings.NewReader(`{
+			"comment": "this comment is way too long for the database column",
+			"stars": 5
+		}`)).AddHeader("Ubi-UserId", "1").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	svc.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Service.addEntry() status = %d want %d", w.Code, http.StatusBadRequest)
+	}
+	var got errorResponse
+	if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+		t.Fatal("failed to decode response", err)
+	}
+	if got.Code != ErrValidation.Code {
+		t.Fatalf("Service.addEntry() code = %q want %q", got.Code, ErrValidation.Code)
+	}
+	want := map[string]string{
+		"stars":     "unknown_field",
+		"rating":    "required",
+		"sessionID": "invalid_characters",
+		"comment":   "too_long",
+	}
+	codes := make(map[string]string)
+	for _, f := range got.Fields {
+		if f.Message == "" {
+			t.Errorf("Service.addEntry() violation without message: %v", f)
+		}
+		codes[f.Field] = f.Code
+	}
+	if !reflect.DeepEqual(codes, want) {
+		t.Fatalf("Service.addEntry() violations = %v want %v", codes, want)
+	}
+}
+
+func TestService_addEntryBodyTooLarge(t *testing.T) {
+	svc := New(log.NewNop(), newMockRepository(nil, nil, nil))
+
+	body := `{"rating": 1, "comment": "` + strings.Repeat("a", maxBodySize) + `"}`
+	req, err := requestbuilder.NewHTTPRequestBuilder("http://127.0.0.1:8080/0").
+		SetMethod("POST").SetBody(strings.NewReader(body)).AddHeader("Ubi-UserId", "1").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	svc.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Service.addEntry() status = %d want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestService_HandlerTimeout(t *testing.T) {
+	svc := New(log.NewNop(), &blockingRepository{newMockRepository(nil, nil, nil)})
+	svc.Timeout = 10 * time.Millisecond
+
+	w := httptest.NewRecorder()
+	svc.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/list", nil))
+
+	if w.Code != ErrTimeout.Status {
+		t.Fatalf("Service.Handler() status = %d want %d", w.Code, ErrTimeout.Status)
+	}
+	var got errorResponse
+	if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+		t.Fatal("failed to decode response", err)
+	}
+	if got.Code != ErrTimeout.Code {
+		t.Fatalf("Service.Handler() code = %q want %q", got.Code, ErrTimeout.Code)
+	}
+}
+
+func TestService_HandlerRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		retryAfter time.Duration
+		want       string
+	}{
+		{"overloaded", ErrOverloaded, 1500 * time.Millisecond, "2"},
+		{"disabled", ErrOverloaded, 0, ""},
+		{"duplicate", ErrDuplicateEntry, time.Second, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := New(log.NewNop(), newMockRepository(func(Entry) error { return tt.err }, nil, nil))
+			svc.RetryAfter = tt.retryAfter
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/session", strings.NewReader(`{"rating": 5}`))
+			r.Header.Set("Ubi-UserId", "user")
+			svc.Handler().ServeHTTP(w, r)
+
+			if got := w.Header().Get("Retry-After"); got != tt.want {
+				t.Fatalf("Retry-After = %q want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestService_HandlerAuth(t *testing.T) {
+	auth := AuthenticatorFunc(func(r *http.Request) (Identity, error) {
+		if r.Header.Get("Authorization") != "Bearer valid" {
+			return Identity{}, ErrUnauthenticated
+		}
+		return Identity{UserID: "verified", Role: RolePlayer}, nil
+	})
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		wantCode int
+	}{
+		{"add", "POST", "/session", "valid", http.StatusOK},
+		{"addUnauthenticated", "POST", "/session", "forged", http.StatusUnauthorized},
+		{"update", "PUT", "/session", "valid", http.StatusOK},
+		{"own", "GET", "/session/me", "valid", http.StatusOK},
+		{"ownUnauthenticated", "GET", "/session/me", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var added Entry
+			repo := newMockRepository(func(e Entry) error { added = e; return nil }, nil, nil)
+			repo.update = func(e Entry) error { added = e; return nil }
+			repo.get = func(session, user string) (Entry, error) { return Entry{SessionID: session, UserID: user}, nil }
+			svc := New(log.NewNop(), repo)
+			svc.Auth = auth
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"rating": 5}`))
+			// the unverified header is ignored
+			r.Header.Set(UserIDHeader, "forged")
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			before := authFailuresTotal.Value()
+			svc.Handler().ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				if authFailuresTotal.Value() != before+1 {
+					t.Error("authentication failure not counted")
+				}
+				return
+			}
+			if tt.method != "GET" && added.UserID != "verified" {
+				t.Errorf("entry stored for user %q want verified", added.UserID)
+			}
+			if tt.method == "GET" && !strings.Contains(w.Body.String(), `"verified"`) {
+				t.Errorf("entry of wrong user returned: %s", w.Body)
+			}
+		})
+	}
+}
+
+func TestService_HandlerRoles(t *testing.T) {
+	routes := []struct {
+		method, path string
+	}{
+		{"GET", "/list"},
+		{"GET", "/stats"},
+		{"GET", "/session"},
+		{"POST", "/session"},
+		{"PUT", "/session"},
+		{"GET", "/session/me"},
+	}
+	tests := []struct {
+		role      Role
+		wantCodes []int
+		// wantUserIDs is set if the ids of other players are visible
+		wantUserIDs bool
+	}{
+		{RolePlayer, []int{403, 403, 403, 200, 200, 200}, false},
+		{RoleReader, []int{200, 200, 200, 403, 403, 403}, false},
+		{RoleModerator, []int{200, 200, 200, 403, 403, 403}, true},
+		{RoleBackend, []int{403, 403, 403, 403, 403, 403}, false},
+		{RoleAdmin, []int{200, 200, 200, 200, 200, 200}, true},
+		{"unknown", []int{403, 403, 403, 403, 403, 403}, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.role), func(t *testing.T) {
+			others := []Entry{{ID: "1", SessionID: "session", UserID: "other", Rating: 5}}
+			repo := newMockRepository(nil, func(uint) ([]Entry, error) { return others, nil }, nil)
+			repo.getBySession = func(string) ([]Entry, error) { return others, nil }
+			repo.get = func(session, user string) (Entry, error) { return Entry{SessionID: session, UserID: user}, nil }
+			svc := New(log.NewNop(), repo)
+			svc.Auth = AuthenticatorFunc(func(r *http.Request) (Identity, error) {
+				return Identity{UserID: "self", Role: tt.role}, nil
+			})
+			h := svc.Handler()
+
+			for i, route := range routes {
+				w := httptest.NewRecorder()
+				before := accessDeniedTotal.Value("/{sessionID}", string(tt.role))
+				h.ServeHTTP(w, httptest.NewRequest(route.method, route.path, strings.NewReader(`{"rating": 5}`)))
+				if w.Code != tt.wantCodes[i] {
+					t.Errorf("%s %s status = %d want %d", route.method, route.path, w.Code, tt.wantCodes[i])
+				}
+				if w.Code == http.StatusForbidden && route.path == "/session" && route.method == "GET" {
+					if got := accessDeniedTotal.Value("/{sessionID}", string(tt.role)); got != before+1 {
+						t.Errorf("%s %s denial not counted", route.method, route.path)
+					}
+				}
+				if w.Code == http.StatusOK && route.method == "GET" && route.path != "/stats" && route.path != "/session/me" {
+					if got := strings.Contains(w.Body.String(), `"other"`); got != tt.wantUserIDs {
+						t.Errorf("%s %s shows user ids = %v want %v: %s", route.method, route.path, got, tt.wantUserIDs, w.Body)
+					}
+				}
+			}
+		})
+	}
+}
+
+// failingLimiter simulates an unreachable shared limiter
+type failingLimiter struct{}
+
+func (failingLimiter) Take(context.Context, string, ratelimit.Limit) (ratelimit.Decision, error) {
+	return ratelimit.Decision{}, errors.New("connection refused")
+}
+
+func TestService_HandlerRateLimit(t *testing.T) {
+	type request struct {
+		user, ip, session string
+	}
+	tests := []struct {
+		name     string
+		limits   RateLimits
+		limiter  ratelimit.Store
+		requests []request
+		// wantCodes of the requests, the last one is checked for headers
+		wantCodes     []int
+		wantRemaining string
+		wantLimited   string
+	}{
+		{
+			name:          "user",
+			limits:        RateLimits{User: ratelimit.Limit{Burst: 2, Period: 2 * time.Minute}},
+			requests:      []request{{"u1", "1.1.1.1", "s1"}, {"u1", "1.1.1.2", "s2"}, {"u1", "1.1.1.3", "s3"}},
+			wantCodes:     []int{200, 200, 429},
+			wantRemaining: "0",
+			wantLimited:   "user",
+		},
+		{
+			name:          "ip",
+			limits:        RateLimits{User: ratelimit.Limit{Burst: 5, Period: time.Minute}, IP: ratelimit.Limit{Burst: 1, Period: time.Minute}},
+			requests:      []request{{"u1", "1.1.1.1", "s1"}, {"u2", "1.1.1.1", "s2"}},
+			wantCodes:     []int{200, 429},
+			wantRemaining: "0",
+			wantLimited:   "ip",
+		},
+		{
+			name:          "session",
+			limits:        RateLimits{Session: ratelimit.Limit{Burst: 1, Period: time.Minute}},
+			requests:      []request{{"u1", "1.1.1.1", "s1"}, {"u2", "1.1.1.2", "s1"}},
+			wantCodes:     []int{200, 429},
+			wantRemaining: "0",
+			wantLimited:   "session",
+		},
+		{
+			name:          "tightest",
+			limits:        RateLimits{User: ratelimit.Limit{Burst: 10, Period: time.Minute}, IP: ratelimit.Limit{Burst: 3, Period: time.Minute}},
+			requests:      []request{{"u1", "1.1.1.1", "s1"}},
+			wantCodes:     []int{200},
+			wantRemaining: "2",
+		},
+		{
+			name:      "disabled",
+			requests:  []request{{"u1", "1.1.1.1", "s1"}, {"u1", "1.1.1.1", "s1"}},
+			wantCodes: []int{200, 200},
+		},
+		{
+			name:      "failOpen",
+			limits:    RateLimits{User: ratelimit.Limit{Burst: 1, Period: time.Minute}},
+			limiter:   failingLimiter{},
+			requests:  []request{{"u1", "1.1.1.1", "s1"}, {"u1", "1.1.1.1", "s1"}},
+			wantCodes: []int{200, 200},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := New(log.NewNop(), newMockRepository(func(Entry) error { return nil }, nil, nil))
+			svc.Limiter = tt.limiter
+			if svc.Limiter == nil {
+				svc.Limiter = ratelimit.NewMemory()
+			}
+			svc.RateLimits = tt.limits
+			svc.ClientIPHeader = "X-Real-Ip"
+			h := svc.Handler()
+
+			var w *httptest.ResponseRecorder
+			var before float64
+			for i, req := range tt.requests {
+				if tt.wantLimited != "" {
+					before = rateLimitedTotal.Value(tt.wantLimited)
+				}
+				w = httptest.NewRecorder()
+				r := httptest.NewRequest("POST", "/"+req.session, strings.NewReader(`{"rating": 5}`))
+				r.Header.Set(UserIDHeader, req.user)
+				r.Header.Set("X-Real-Ip", req.ip)
+				h.ServeHTTP(w, r)
+				if w.Code != tt.wantCodes[i] {
+					t.Fatalf("request %d status = %d want %d: %s", i, w.Code, tt.wantCodes[i], w.Body)
+				}
+			}
+
+			if got := w.Header().Get("X-RateLimit-Remaining"); got != tt.wantRemaining {
+				t.Errorf("X-RateLimit-Remaining = %q want %q", got, tt.wantRemaining)
+			}
+			if tt.wantLimited == "" {
+				return
+			}
+			if got := w.Header().Get("Retry-After"); got == "" || got == "0" {
+				t.Errorf("Retry-After = %q want the seconds until a token is available", got)
+			}
+			if !strings.Contains(w.Body.String(), ErrRateLimited.Code) {
+				t.Errorf("body = %s want code %q", w.Body, ErrRateLimited.Code)
+			}
+			if got := rateLimitedTotal.Value(tt.wantLimited); got != before+1 {
+				t.Errorf("rejection by %s limit not counted", tt.wantLimited)
+			}
+		})
+	}
+}
+
+func TestService_clientIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		values []string
+		want   string
+	}{
+		{"remoteAddr", "", nil, "192.0.2.1"},
+		{"headerMissing", "X-Forwarded-For", nil, "192.0.2.1"},
+		{"single", "X-Real-Ip", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"spoofedList", "X-Forwarded-For", []string{"10.0.0.1, 203.0.113.7"}, "203.0.113.7"},
+		{"spoofedHeader", "X-Forwarded-For", []string{"10.0.0.1", "198.51.100.2,203.0.113.7"}, "203.0.113.7"},
+		{"ipv6", "X-Forwarded-For", []string{"2001:db8::1"}, "2001:db8::1"},
+		{"invalid", "X-Forwarded-For", []string{"10.0.0.1, random"}, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := New(log.NewNop(), newMockRepository(nil, nil, nil))
+			svc.ClientIPHeader = tt.header
+			r := httptest.NewRequest("POST", "/s1", nil)
+			for _, v := range tt.values {
+				r.Header.Add(tt.header, v)
+			}
+			if got := svc.clientIP(r); got != tt.want {
+				t.Errorf("clientIP() = %q want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// idempotencyStore keeps idempotency records in a map
+type idempotencyStore map[string]*IdempotencyRecord
+
+func (s idempotencyStore) Reserve(ctx context.Context, userID, key, hash string, lease time.Duration) (IdempotencyRecord, bool, error) {
+	if rec, ok := s[userID+"/"+key]; ok {
+		return *rec, false, nil
+	}
+	s[userID+"/"+key] = &IdempotencyRecord{Hash: hash}
+	return IdempotencyRecord{}, true, nil
+}
+
+func (s idempotencyStore) Complete(ctx context.Context, userID, key string, resp Response) error {
+	s[userID+"/"+key].Response = &resp
+	return nil
+}
+
+func (s idempotencyStore) Release(ctx context.Context, userID, key string) error {
+	delete(s, userID+"/"+key)
+	return nil
+}
+
+func TestService_HandlerIdempotency(t *testing.T) {
+	type request struct {
+		user, key, body string
+	}
+	tests := []struct {
+		name string
+		// errs returned by the repository for each call to Add
+		errs []error
+		// inProgress reserves the key of the first request as if it was being processed elsewhere
+		inProgress bool
+		requests   []request
+		wantCodes  []int
+		wantAdds   int
+		// wantReplay is set if the last response has to repeat the first one
+		wantReplay bool
+	}{
+		{
+			name:       "replayed",
+			requests:   []request{{"u1", "k1", `{"rating": 5}`}, {"u1", "k1", `{"rating": 5}`}},
+			wantCodes:  []int{200, 200},
+			wantAdds:   1,
+			wantReplay: true,
+		},
+		{
+			name:       "replayedError",
+			errs:       []error{ErrDuplicateEntry},
+			requests:   []request{{"u1", "k1", `{"rating": 5}`}, {"u1", "k1", `{"rating": 5}`}},
+			wantCodes:  []int{409, 409},
+			wantAdds:   1,
+			wantReplay: true,
+		},
+		{
+			name:      "retriedAfterOverload",
+			errs:      []error{ErrOverloaded, nil},
+			requests:  []request{{"u1", "k1", `{"rating": 5}`}, {"u1", "k1", `{"rating": 5}`}},
+			wantCodes: []int{503, 200},
+			wantAdds:  2,
+		},
+		{
+			name:      "differentPayload",
+			requests:  []request{{"u1", "k1", `{"rating": 5}`}, {"u1", "k1", `{"rating": 1}`}},
+			wantCodes: []int{200, 422},
+			wantAdds:  1,
+		},
+		{
+			name:      "otherUser",
+			requests:  []request{{"u1", "k1", `{"rating": 5}`}, {"u2", "k1", `{"rating": 5}`}},
+			wantCodes: []int{200, 200},
+			wantAdds:  2,
+		},
+		{
+			name:       "inProgress",
+			inProgress: true,
+			requests:   []request{{"u1", "k1", `{"rating": 5}`}},
+			wantCodes:  []int{409},
+		},
+		{
+			name:      "withoutKey",
+			requests:  []request{{"u1", "", `{"rating": 5}`}, {"u1", "", `{"rating": 5}`}},
+			wantCodes: []int{200, 200},
+			wantAdds:  2,
+		},
+		{
+			name:      "keyTooLong",
+			requests:  []request{{"u1", strings.Repeat("k", 101), `{"rating": 5}`}},
+			wantCodes: []int{400},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var adds int
+			repo := newMockRepository(func(Entry) error {
+				adds++
+				if adds <= len(tt.errs) {
+					return tt.errs[adds-1]
+				}
+				return nil
+			}, nil, nil)
+			store := idempotencyStore{}
+			if tt.inProgress {
+				req := tt.requests[0]
+				hash, err := fingerprint(httptest.NewRequest("POST", "/session", strings.NewReader(req.body)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				store[req.user+"/"+req.key] = &IdempotencyRecord{Hash: hash}
+			}
+			svc := New(log.NewNop(), repo)
+			svc.Idempotency = store
+			h := svc.Handler()
+
+			var first, last *httptest.ResponseRecorder
+			for i, req := range tt.requests {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest("POST", "/session", strings.NewReader(req.body))
+				r.Header.Set(UserIDHeader, req.user)
+				if req.key != "" {
+					r.Header.Set(IdempotencyKeyHeader, req.key)
+				}
+				h.ServeHTTP(w, r)
+				if w.Code != tt.wantCodes[i] {
+					t.Fatalf("request %d status = %d want %d: %s", i, w.Code, tt.wantCodes[i], w.Body)
+				}
+				if first == nil {
+					first = w
+				}
+				last = w
+			}
+
+			if adds != tt.wantAdds {
+				t.Errorf("Add() called %d times want %d", adds, tt.wantAdds)
+			}
+			replayed := last.Header().Get(ReplayedHeader) == "true"
+			if replayed != tt.wantReplay {
+				t.Errorf("last response replayed = %v want %v", replayed, tt.wantReplay)
+			}
+			if tt.wantReplay && (last.Body.String() != first.Body.String() || last.Header().Get("content-type") != first.Header().Get("content-type")) {
+				t.Errorf("replayed response %q differs from first response %q", last.Body, first.Body)
+			}
+		})
+	}
+}
+
+func TestService_HandlerSessions(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	registration := `{"participants": ["u2", "u1"], "startedAt": "` + now.Add(-time.Hour).Format(time.RFC3339) + `", "ratingWindow": 3600}`
+	tests := []struct {
+		name     string
+		role     Role
+		user     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+		// wantBody is contained in the response
+		wantBody string
+	}{
+		{"register", RoleBackend, "game", "PUT", "/sessions/s1", registration, 200, `"participants": [
+    "u1",
+    "u2"
+  ]`},
+		{"registerInvalid", RoleBackend, "game", "PUT", "/sessions/s1", `{"participants": []}`, 400, `"ratingWindow","code":"required"`},
+		{"registerZeroWindow", RoleBackend, "game", "PUT", "/sessions/s1", strings.Replace(registration, "3600", "0", 1), 400, `"ratingWindow","code":"invalid_rating_window"`},
+		{"registerOverflowingWindow", RoleBackend, "game", "PUT", "/sessions/s1", strings.Replace(registration, "3600", "9223372036854775807", 1), 400, `"ratingWindow","code":"invalid_rating_window"`},
+		{"registerUnknownField", RoleBackend, "game", "PUT", "/sessions/s1", `{"players": ["u1"]}`, 400, ErrInvalidBody.Code},
+		{"registerAsPlayer", RolePlayer, "u1", "PUT", "/sessions/s1", registration, 403, ErrForbidden.Code},
+		{"get", RoleBackend, "game", "GET", "/sessions/s0", "", 200, `"sessionID": "s0"`},
+		{"getUnknown", RoleBackend, "game", "GET", "/sessions/s2", "", 404, ErrUnknownSession.Code},
+		{"rate", RolePlayer, "u1", "POST", "/s0", `{"rating": 5}`, 200, "{}"},
+		{"rateUnknown", RolePlayer, "u1", "POST", "/s2", `{"rating": 5}`, 404, ErrUnknownSession.Code},
+		{"rateNotParticipant", RolePlayer, "u3", "POST", "/s0", `{"rating": 5}`, 403, ErrNotParticipant.Code},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := New(log.NewNop(), newMockRepository(func(Entry) error { return nil }, nil, nil))
+			svc.Sessions = sessionStore{"s0": {ID: "s0", Participants: []string{"u1"}, StartedAt: now.Add(-time.Hour), RatingWindow: time.Hour}}
+			svc.Auth = AuthenticatorFunc(func(r *http.Request) (Identity, error) {
+				return Identity{UserID: tt.user, Role: tt.role}, nil
+			})
+
+			w := httptest.NewRecorder()
+			svc.Handler().ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s want it to contain %s", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestService_HandlerSessionsDisabled(t *testing.T) {
+	svc := New(log.NewNop(), newMockRepository(func(Entry) error { return nil }, nil, nil))
+	h := svc.Handler()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("PUT", "/sessions/s1", strings.NewReader(`{}`)))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("registry status = %d want %d", w.Code, http.StatusNotFound)
+	}
+	// any session can be rated without the registry
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/unregistered", strings.NewReader(`{"rating": 5}`))
+	r.Header.Set(UserIDHeader, "u1")
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST status = %d want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+}
+
+// blockingRepository only returns from GetLatest once its context is done
+type blockingRepository struct {
+	*mockRepository
+}
+
+func (r *blockingRepository) GetLatest(ctx context.Context, n uint) ([]Entry, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
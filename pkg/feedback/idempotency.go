@@ -0,0 +1,173 @@
+package feedback
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// IdempotencyKeyHeader lets clients retry a submission, getting the response of the first attempt
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// ReplayedHeader marks responses replayed for an idempotency key
+	ReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 100
+	// idempotencyStoreTimeout for storing the response, which happens even if the request timed out
+	idempotencyStoreTimeout = 5 * time.Second
+)
+
+// Response to a request, stored for its idempotency key
+type Response struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// IdempotencyRecord of a request sent with an idempotency key
+type IdempotencyRecord struct {
+	// Hash fingerprinting method, path and body of the request
+	Hash string
+	// Response to the request, nil while it is in progress
+	Response *Response
+}
+
+// IdempotencyStore keeps the first response to requests sent with an idempotency key, per user
+type IdempotencyStore interface {
+	// Reserve key for a request with the fingerprint hash. If the key is already taken, ok is false
+	// and the record of the first request is returned. Reservations still in progress after lease
+	// are considered abandoned by a crashed request and taken over, never if lease is zero.
+	Reserve(ctx context.Context, userID, key, hash string, lease time.Duration) (rec IdempotencyRecord, ok bool, err error)
+	// Complete the reservation of key, storing the response to replay
+	Complete(ctx context.Context, userID, key string, resp Response) error
+	// Release the reservation of key, so the request can be retried
+	Release(ctx context.Context, userID, key string) error
+}
+
+// idempotent h, replaying the stored response to requests repeating the IdempotencyKeyHeader of an earlier one.
+// Responses telling the client to retry later are not stored, so retries are processed again.
+func (s *Service) idempotent(h handler) handler {
+	return func(w http.ResponseWriter, r *http.Request) (err error) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if s.Idempotency == nil || key == "" {
+			return h(w, r)
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			s.deferError(w, r, ErrInvalidIdempotencyKey)
+			return ErrInvalidIdempotencyKey
+		}
+		id, err := s.identity(r)
+		if err != nil {
+			s.deferError(w, r, err)
+			return err
+		}
+		hash, err := fingerprint(r)
+		if err != nil {
+			err = ErrInvalidBody.Wrap(err)
+			s.deferError(w, r, err)
+			return err
+		}
+
+		rec, ok, err := s.Idempotency.Reserve(r.Context(), id.UserID, key, hash, s.idempotencyLease())
+		if err != nil {
+			s.deferError(w, r, err)
+			return err
+		}
+		if !ok {
+			return s.replay(w, r, rec, hash)
+		}
+
+		resp := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		err = h(resp, r)
+
+		ctx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTimeout)
+		defer cancel()
+		if storable(resp.status) {
+			stored := Response{Status: resp.status, ContentType: resp.Header().Get("content-type"), Body: resp.body.Bytes()}
+			if err := s.Idempotency.Complete(ctx, id.UserID, key, stored); err != nil {
+				s.Error("storing idempotent response failed", zap.String("key", key), zap.Error(err))
+			}
+		} else if err := s.Idempotency.Release(ctx, id.UserID, key); err != nil {
+			s.Error("releasing idempotency key failed", zap.String("key", key), zap.Error(err))
+		}
+		return err
+	}
+}
+
+// idempotencyLease after which reservations are taken over by retries, leaving the first request time to
+// time out and store its response. Without a request timeout reservations are kept until they expire.
+func (s *Service) idempotencyLease() time.Duration {
+	if s.Timeout == 0 {
+		return 0
+	}
+	return s.Timeout + idempotencyStoreTimeout
+}
+
+// replay the response of rec to a request with the fingerprint hash
+func (s *Service) replay(w http.ResponseWriter, r *http.Request, rec IdempotencyRecord, hash string) error {
+	switch {
+	case rec.Hash != hash:
+		s.deferError(w, r, ErrIdempotencyKeyReused)
+		return ErrIdempotencyKeyReused
+	case rec.Response == nil:
+		s.deferError(w, r, ErrIdempotencyKeyInUse)
+		return ErrIdempotencyKeyInUse
+	}
+	idempotentReplaysTotal.Inc()
+	if rec.Response.ContentType != "" {
+		w.Header().Set("content-type", rec.Response.ContentType)
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(rec.Response.Status)
+	_, err := w.Write(rec.Response.Body)
+	return err
+}
+
+// storable reports whether a response with status is final, rather than asking the client to retry
+func storable(status int) bool {
+	return status < http.StatusInternalServerError && status != http.StatusTooManyRequests
+}
+
+// fingerprint of method, path and body of r, leaving the body to be read again
+func fingerprint(r *http.Request) (string, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		return "", err
+	}
+	// bodies exceeding the limit are rejected by the handler
+	r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// responseRecorder passes a response on, keeping a copy of its status and body
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,93 @@
+package feedback
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/metrics"
+)
+
+var (
+	requestsTotal = metrics.NewCounterVec(
+		"feedback_http_requests_total",
+		"HTTP requests by route, method and status code.",
+		"route", "method", "code",
+	)
+	requestDuration = metrics.NewHistogramVec(
+		"feedback_http_request_duration_seconds",
+		"HTTP request latency by route and method.",
+		nil,
+		"route", "method",
+	)
+	submissionsTotal = metrics.NewCounterVec(
+		"feedback_submissions_total",
+		"Accepted feedback submissions by rating.",
+		"rating",
+	)
+	duplicatesTotal = metrics.NewCounterVec(
+		"feedback_duplicate_rejections_total",
+		"Feedback submissions rejected as duplicate of an existing entry.",
+	)
+	ineligibleTotal = metrics.NewCounterVec(
+		"feedback_ineligible_rejections_total",
+		"Submissions rejected by the session registry, by error code.",
+		"code",
+	)
+	idempotentReplaysTotal = metrics.NewCounterVec(
+		"feedback_idempotent_replays_total",
+		"Submissions answered with the stored response to an earlier request with the same idempotency key.",
+	)
+	authFailuresTotal = metrics.NewCounterVec(
+		"feedback_auth_failures_total",
+		"Requests rejected for missing or invalid credentials.",
+	)
+	rateLimitedTotal = metrics.NewCounterVec(
+		"feedback_rate_limited_total",
+		"Submissions rejected for exceeding a rate limit, by limit.",
+		"limit",
+	)
+	accessDeniedTotal = metrics.NewCounterVec(
+		"feedback_access_denied_total",
+		"Requests rejected as their role lacks permission, by route and role.",
+		"route", "role",
+	)
+)
+
+// instrument h with request count, status and latency metrics
+func instrument(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h(rec, r)
+
+		route := routeName(r)
+		requestsTotal.Inc(route, r.Method, strconv.Itoa(rec.status))
+		requestDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+	}
+}
+
+// routeName returns the path template of the matched route, keeping the label cardinality low
+func routeName(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return "unknown"
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
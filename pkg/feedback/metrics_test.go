@@ -0,0 +1,77 @@
+package feedback
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/playnet-public/libs/log"
+)
+
+func TestInstrument(t *testing.T) {
+	svc := New(log.NewNop(), newMockRepository(nil, nil, nil))
+	h := svc.Handler()
+
+	before := requestsTotal.Value("/list", "GET", "200")
+	beforeBad := requestsTotal.Value("/list", "GET", "400")
+	beforeCount := requestDuration.Count("/list", "GET")
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/list", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/list?limit=x", nil))
+
+	if got := requestsTotal.Value("/list", "GET", "200") - before; got != 1 {
+		t.Errorf("requests with status 200 = %v want 1", got)
+	}
+	if got := requestsTotal.Value("/list", "GET", "400") - beforeBad; got != 1 {
+		t.Errorf("requests with status 400 = %v want 1", got)
+	}
+	if got := requestDuration.Count("/list", "GET") - beforeCount; got != 2 {
+		t.Errorf("observed latencies = %v want 2", got)
+	}
+
+	// requests for sessions share the route template as label
+	before = requestsTotal.Value("/{sessionID}", "GET", "200")
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abc", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/def", nil))
+	if got := requestsTotal.Value("/{sessionID}", "GET", "200") - before; got != 2 {
+		t.Errorf("session requests = %v want 2", got)
+	}
+}
+
+func TestService_AddMetrics(t *testing.T) {
+	repo := newMockRepository(nil, nil, nil)
+	svc := New(log.NewNop(), repo)
+
+	before := submissionsTotal.Value("4")
+	beforeDup := duplicatesTotal.Value()
+
+	if err := svc.Add(context.Background(), Entry{SessionID: "1", UserID: "1", Rating: 4}); err != nil {
+		t.Fatal(err)
+	}
+	repo.add = func(Entry) error { return ErrDuplicateEntry }
+	if err := svc.Add(context.Background(), Entry{SessionID: "1", UserID: "1", Rating: 4}); err != ErrDuplicateEntry {
+		t.Fatal(err)
+	}
+
+	if got := submissionsTotal.Value("4") - before; got != 1 {
+		t.Errorf("submissions = %v want 1", got)
+	}
+	if got := duplicatesTotal.Value() - beforeDup; got != 1 {
+		t.Errorf("duplicate rejections = %v want 1", got)
+	}
+}
+
+func TestStatusRecorder(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	rec.WriteHeader(http.StatusConflict)
+	rec.WriteHeader(http.StatusOK)
+	if rec.status != http.StatusConflict {
+		t.Fatalf("status = %d want %d", rec.status, http.StatusConflict)
+	}
+	if !strings.Contains(routeName(httptest.NewRequest("GET", "/", nil)), "unknown") {
+		t.Fatal("routeName() outside of router should be unknown")
+	}
+}
@@ -0,0 +1,50 @@
+package feedback
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+const cursorPrefix = "id:"
+
+// Page of entries with the cursor to the next (older) page, empty if there is none
+type Page struct {
+	Entries []Entry `json:"entries"`
+	Next    string  `json:"next,omitempty"`
+}
+
+// newPage from up to n+1 entries, using the additional one to detect further pages
+func newPage(entries []Entry, n uint) Page {
+	if entries == nil {
+		entries = []Entry{}
+	}
+	if uint(len(entries)) <= n {
+		return Page{Entries: entries}
+	}
+	entries = entries[:n]
+	var next string
+	if n > 0 {
+		next = encodeCursor(entries[n-1].ID)
+	}
+	return Page{Entries: entries, Next: next}
+}
+
+func encodeCursor(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + id))
+}
+
+// decodeCursor into the entry id it points at, an empty cursor starts at the newest entry
+func decodeCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", ErrInvalidCursor
+	}
+	id := string(data)
+	if !strings.HasPrefix(id, cursorPrefix) || len(id) == len(cursorPrefix) {
+		return "", ErrInvalidCursor
+	}
+	return strings.TrimPrefix(id, cursorPrefix), nil
+}
@@ -0,0 +1,69 @@
+package feedback
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	for _, id := range []string{"1", "42", "abc"} {
+		got, err := decodeCursor(encodeCursor(id))
+		if err != nil {
+			t.Fatal("decodeCursor() error", err)
+		}
+		if got != id {
+			t.Fatalf("decodeCursor() = %q want %q", got, id)
+		}
+	}
+
+	if got, err := decodeCursor(""); err != nil || got != "" {
+		t.Fatalf("decodeCursor(\"\") = %q, %v", got, err)
+	}
+	for _, c := range []string{"!!!", "MQ", encodeCursor("")} {
+		if _, err := decodeCursor(c); err != ErrInvalidCursor {
+			t.Fatalf("decodeCursor(%q) = %v want %v", c, err, ErrInvalidCursor)
+		}
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	entries := []Entry{{ID: "5"}, {ID: "4"}, {ID: "3"}}
+	tests := []struct {
+		name    string
+		entries []Entry
+		n       uint
+		want    Page
+	}{
+		{
+			"empty",
+			nil,
+			2,
+			Page{Entries: []Entry{}},
+		},
+		{
+			"lastPage",
+			entries[:2],
+			2,
+			Page{Entries: entries[:2]},
+		},
+		{
+			"morePages",
+			entries,
+			2,
+			Page{Entries: entries[:2], Next: encodeCursor("4")},
+		},
+		{
+			"zeroLimit",
+			entries[:1],
+			0,
+			Page{Entries: []Entry{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newPage(tt.entries, tt.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newPage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package feedback
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/ratelimit"
+	"go.uber.org/zap"
+)
+
+// RateLimits of submissions per player, client IP and session. Zero limits are not enforced.
+type RateLimits struct {
+	User    ratelimit.Limit
+	IP      ratelimit.Limit
+	Session ratelimit.Limit
+}
+
+// limit h by the RateLimits, rejecting requests exceeding any of them with ErrRateLimited.
+// Submissions are allowed if the Limiter fails, so an outage of a shared limiter doesn't stop them.
+func (s *Service) limit(h handler) handler {
+	return func(w http.ResponseWriter, r *http.Request) (err error) {
+		if s.Limiter == nil {
+			return h(w, r)
+		}
+		id, err := s.identity(r)
+		if err != nil {
+			s.deferError(w, r, err)
+			return err
+		}
+		rules := []struct {
+			name  string
+			key   string
+			limit ratelimit.Limit
+		}{
+			{"user", id.UserID, s.RateLimits.User},
+			{"ip", s.clientIP(r), s.RateLimits.IP},
+			{"session", mux.Vars(r)["sessionID"], s.RateLimits.Session},
+		}
+
+		var tightest *ratelimit.Decision
+		for _, rule := range rules {
+			if rule.limit.IsZero() || rule.key == "" {
+				continue
+			}
+			d, err := s.Limiter.Take(r.Context(), rule.name+":"+rule.key, rule.limit)
+			if err != nil {
+				s.Error("rate limiter failed", zap.String("limit", rule.name), zap.Error(err))
+				continue
+			}
+			if !d.Allowed {
+				rateLimitedTotal.Inc(rule.name)
+				s.Warn("rate limited",
+					zap.String("limit", rule.name),
+					zap.String("key", rule.key),
+					zap.Duration("retryAfter", d.RetryAfter),
+				)
+				setRateLimitHeaders(w, d)
+				w.Header().Set("Retry-After", ceilSeconds(d.RetryAfter))
+				s.deferError(w, r, ErrRateLimited)
+				return ErrRateLimited
+			}
+			if tightest == nil || d.Remaining < tightest.Remaining {
+				tightest = &d
+			}
+		}
+		if tightest != nil {
+			setRateLimitHeaders(w, *tightest)
+		}
+		return h(w, r)
+	}
+}
+
+// setRateLimitHeaders describing the limit closest to being exceeded
+func setRateLimitHeaders(w http.ResponseWriter, d ratelimit.Decision) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(d.Limit.Burst))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	w.Header().Set("X-RateLimit-Reset", ceilSeconds(d.Reset))
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// clientIP of r, taken from ClientIPHeader if the service is behind a proxy setting it.
+// Only the right-most address of lists like X-Forwarded-For is used, as that is the one appended by
+// the proxy while the others are sent by the client. The remote address is used if it isn't valid.
+func (s *Service) clientIP(r *http.Request) string {
+	if s.ClientIPHeader != "" {
+		values := r.Header[http.CanonicalHeaderKey(s.ClientIPHeader)]
+		if len(values) > 0 {
+			addrs := strings.Split(values[len(values)-1], ",")
+			if ip := net.ParseIP(strings.TrimSpace(addrs[len(addrs)-1])); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -1,8 +1,24 @@
 package feedback
 
-// Repository interface for storing feedback
+import "context"
+
+// Repository interface for storing feedback.
+// All methods take the context of the request they serve, carrying its trace span.
 type Repository interface {
-	Add(Entry) error
-	GetLatest(n uint) ([]Entry, error)
-	GetLatestFiltered(n uint, filter int) ([]Entry, error)
+	Add(ctx context.Context, entry Entry) error
+	// Update rating and comment of the entry with matching session and user, ErrNotFound if there is none
+	Update(ctx context.Context, entry Entry) error
+	// Get the entry of a user for a session, ErrNotFound if there is none
+	Get(ctx context.Context, sessionID, userID string) (Entry, error)
+	GetLatest(ctx context.Context, n uint) ([]Entry, error)
+	GetLatestFiltered(ctx context.Context, n uint, filter int) ([]Entry, error)
+	GetLatestInRange(ctx context.Context, n uint, r Range) ([]Entry, error)
+	GetLatestFilteredInRange(ctx context.Context, n uint, filter int, r Range) ([]Entry, error)
+	// GetLatestBefore returns entries older than the entry with id before, starting at the newest if before is empty
+	GetLatestBefore(ctx context.Context, n uint, before string, r Range) ([]Entry, error)
+	GetLatestFilteredBefore(ctx context.Context, n uint, filter int, before string, r Range) ([]Entry, error)
+	// GetBySession returns all entries of a session, newest first
+	GetBySession(ctx context.Context, sessionID string) ([]Entry, error)
+	// GetStats aggregates all entries created within r, grouping them by session as well if bySession is set
+	GetStats(ctx context.Context, r Range, bySession bool) (Stats, error)
 }
@@ -1,9 +1,24 @@
 package feedback
 
+import "context"
+
 type mockRepository struct {
 	add               func(Entry) error
 	getLatest         func(uint) ([]Entry, error)
 	getLatestFiltered func(uint, int) ([]Entry, error)
+
+	getLatestInRange         func(uint, Range) ([]Entry, error)
+	getLatestFilteredInRange func(uint, int, Range) ([]Entry, error)
+
+	getLatestBefore         func(uint, string, Range) ([]Entry, error)
+	getLatestFilteredBefore func(uint, int, string, Range) ([]Entry, error)
+
+	getBySession func(string) ([]Entry, error)
+
+	update func(Entry) error
+	get    func(string, string) (Entry, error)
+
+	getStats func(Range, bool) (Stats, error)
 }
 
 func newMockRepository(
@@ -33,14 +48,70 @@ func newMockRepository(
 	}
 }
 
-func (m *mockRepository) Add(entry Entry) error {
+func (m *mockRepository) Add(ctx context.Context, entry Entry) error {
 	return m.add(entry)
 }
 
-func (m *mockRepository) GetLatest(n uint) ([]Entry, error) {
+func (m *mockRepository) GetLatest(ctx context.Context, n uint) ([]Entry, error) {
 	return m.getLatest(n)
 }
 
-func (m *mockRepository) GetLatestFiltered(n uint, filter int) ([]Entry, error) {
+func (m *mockRepository) GetLatestFiltered(ctx context.Context, n uint, filter int) ([]Entry, error) {
 	return m.getLatestFiltered(n, filter)
 }
+
+func (m *mockRepository) GetLatestInRange(ctx context.Context, n uint, r Range) ([]Entry, error) {
+	if m.getLatestInRange == nil {
+		return []Entry{}, nil
+	}
+	return m.getLatestInRange(n, r)
+}
+
+func (m *mockRepository) GetLatestFilteredInRange(ctx context.Context, n uint, filter int, r Range) ([]Entry, error) {
+	if m.getLatestFilteredInRange == nil {
+		return []Entry{}, nil
+	}
+	return m.getLatestFilteredInRange(n, filter, r)
+}
+
+func (m *mockRepository) GetLatestBefore(ctx context.Context, n uint, before string, r Range) ([]Entry, error) {
+	if m.getLatestBefore == nil {
+		return []Entry{}, nil
+	}
+	return m.getLatestBefore(n, before, r)
+}
+
+func (m *mockRepository) GetLatestFilteredBefore(ctx context.Context, n uint, filter int, before string, r Range) ([]Entry, error) {
+	if m.getLatestFilteredBefore == nil {
+		return []Entry{}, nil
+	}
+	return m.getLatestFilteredBefore(n, filter, before, r)
+}
+
+func (m *mockRepository) GetBySession(ctx context.Context, sessionID string) ([]Entry, error) {
+	if m.getBySession == nil {
+		return []Entry{}, nil
+	}
+	return m.getBySession(sessionID)
+}
+
+func (m *mockRepository) Update(ctx context.Context, entry Entry) error {
+	if m.update == nil {
+		return nil
+	}
+	return m.update(entry)
+}
+
+func (m *mockRepository) Get(ctx context.Context, sessionID, userID string) (Entry, error) {
+	if m.get == nil {
+		return Entry{}, ErrNotFound
+	}
+	return m.get(sessionID, userID)
+}
+
+func (m *mockRepository) GetStats(ctx context.Context, r Range, bySession bool) (Stats, error) {
+	if m.getStats == nil {
+		return Stats{Summary: SummarizeHistogram(nil)}, nil
+	}
+	return m.getStats(r, bySession)
+}
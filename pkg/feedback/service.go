@@ -1,6 +1,11 @@
 package feedback
 
 import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/ratelimit"
 	"github.com/playnet-public/libs/log"
 	"go.uber.org/zap"
 )
@@ -9,31 +14,157 @@ import (
 type Service struct {
 	*log.Logger
 	repo Repository
+
+	// Timeout of a single request including all its queries, unlimited if zero
+	Timeout time.Duration
+	// Auth verifies the players sending entries, TrustHeader if not set
+	Auth Authenticator
+	// Limiter keeps the buckets of RateLimits, submissions are not limited if nil
+	Limiter    ratelimit.Store
+	RateLimits RateLimits
+	// ClientIPHeader is set by a trusted proxy to the address of the client, the remote address is used if empty
+	ClientIPHeader string
+	// Idempotency keeps the responses to submissions sent with an IdempotencyKeyHeader, which is ignored if nil
+	Idempotency IdempotencyStore
+	// Sessions registered by the game backend, only their participants may rate them within their rating window.
+	// Any session can be rated by anyone if nil.
+	Sessions SessionStore
+	// RetryAfter is sent to clients with 503 responses, telling them when to try again
+	RetryAfter time.Duration
+
+	now func() time.Time
 }
 
 // New Service for getting feedback
 func New(log *log.Logger, repo Repository) *Service {
 	log = log.WithFields(zap.String("component", "feedback.service"))
 	return &Service{
-		Logger: log,
-		repo:   repo,
+		Logger:     log,
+		repo:       repo,
+		Auth:       TrustHeader,
+		RetryAfter: time.Second,
+		now:        time.Now,
 	}
 }
 
+const (
+	minRating = 1
+	maxRating = 5
+)
+
 // Add entry to Repository
-func (s *Service) Add(entry Entry) error {
-	if entry.Rating > 5 || entry.Rating < 1 {
-		return ErrInvalidRating
+func (s *Service) Add(ctx context.Context, entry Entry) error {
+	if err := validate(entry); err != nil {
+		return err
+	}
+	if err := s.checkEligible(ctx, entry); err != nil {
+		return err
+	}
+	err := s.repo.Add(ctx, entry)
+	switch err {
+	case nil:
+		submissionsTotal.Inc(strconv.Itoa(int(entry.Rating)))
+	case ErrDuplicateEntry:
+		duplicatesTotal.Inc()
+	}
+	return err
+}
+
+// Update rating and comment of an existing entry in Repository
+func (s *Service) Update(ctx context.Context, entry Entry) error {
+	if err := validate(entry); err != nil {
+		return err
+	}
+	if err := s.checkEligible(ctx, entry); err != nil {
+		return err
 	}
-	return s.repo.Add(entry)
+	return s.repo.Update(ctx, entry)
+}
+
+// Get the entry of a user for a session from Repository
+func (s *Service) Get(ctx context.Context, sessionID, userID string) (Entry, error) {
+	if len(sessionID) < 1 {
+		return Entry{}, ErrNoSession
+	}
+	if len(userID) < 1 {
+		return Entry{}, ErrNoUserID
+	}
+	return s.repo.Get(ctx, sessionID, userID)
 }
 
 // GetLatest n entries from Repository
-func (s *Service) GetLatest(n uint) ([]Entry, error) {
-	return s.repo.GetLatest(n)
+func (s *Service) GetLatest(ctx context.Context, n uint) ([]Entry, error) {
+	return s.repo.GetLatest(ctx, n)
 }
 
 // GetLatestFiltered n entries by rating from Repository
-func (s *Service) GetLatestFiltered(n uint, filter int) ([]Entry, error) {
-	return s.repo.GetLatestFiltered(n, filter)
+func (s *Service) GetLatestFiltered(ctx context.Context, n uint, filter int) ([]Entry, error) {
+	return s.repo.GetLatestFiltered(ctx, n, filter)
+}
+
+// GetLatestInRange n entries created within r from Repository
+func (s *Service) GetLatestInRange(ctx context.Context, n uint, r Range) ([]Entry, error) {
+	return s.repo.GetLatestInRange(ctx, n, r)
+}
+
+// GetLatestFilteredInRange n entries by rating created within r from Repository
+func (s *Service) GetLatestFilteredInRange(ctx context.Context, n uint, filter int, r Range) ([]Entry, error) {
+	return s.repo.GetLatestFilteredInRange(ctx, n, filter, r)
+}
+
+// GetPage of n entries created within r, continuing at cursor
+func (s *Service) GetPage(ctx context.Context, n uint, cursor string, r Range) (Page, error) {
+	before, err := decodeCursor(cursor)
+	if err != nil {
+		return Page{}, err
+	}
+	entries, err := s.repo.GetLatestBefore(ctx, n+1, before, r)
+	if err != nil {
+		return Page{}, err
+	}
+	return newPage(entries, n), nil
+}
+
+// GetPageFiltered of n entries by rating created within r, continuing at cursor
+func (s *Service) GetPageFiltered(ctx context.Context, n uint, filter int, cursor string, r Range) (Page, error) {
+	before, err := decodeCursor(cursor)
+	if err != nil {
+		return Page{}, err
+	}
+	entries, err := s.repo.GetLatestFilteredBefore(ctx, n+1, filter, before, r)
+	if err != nil {
+		return Page{}, err
+	}
+	return newPage(entries, n), nil
+}
+
+// SessionFeedback lists all entries of a session and their summary
+type SessionFeedback struct {
+	SessionID string  `json:"sessionID"`
+	Entries   []Entry `json:"entries"`
+	Summary   Summary `json:"summary"`
+}
+
+// GetBySession returns all entries of a session from Repository
+func (s *Service) GetBySession(ctx context.Context, sessionID string) (SessionFeedback, error) {
+	if len(sessionID) < 1 {
+		return SessionFeedback{}, ErrNoSession
+	}
+	entries, err := s.repo.GetBySession(ctx, sessionID)
+	if err != nil {
+		return SessionFeedback{}, err
+	}
+	if entries == nil {
+		entries = []Entry{}
+	}
+	return SessionFeedback{
+		SessionID: sessionID,
+		Entries:   entries,
+		Summary:   Summarize(entries),
+	}, nil
+}
+
+// GetStats of all entries created within r from Repository
+func (s *Service) GetStats(ctx context.Context, r Range, bySession bool) (Stats, error) {
+	return s.repo.GetStats(ctx, r, bySession)
 }
@@ -1,8 +1,11 @@
 package feedback
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/playnet-public/libs/log"
 )
@@ -21,18 +24,18 @@ func TestService_Add(t *testing.T) {
 		t.Errorf("New() == nil")
 	}
 
-	e := Entry{Rating: 0}
-	err := svc.Add(e)
-	if err != ErrInvalidRating {
+	e := Entry{SessionID: "1", UserID: "1", Rating: 0}
+	err := svc.Add(context.Background(), e)
+	if fieldCode(err, "rating") != ErrInvalidRating.Code {
 		t.Fatal("Add() should return error")
 	}
-	e = Entry{Rating: 6}
-	err = svc.Add(e)
-	if err != ErrInvalidRating {
+	e = Entry{SessionID: "1", UserID: "1", Rating: 6}
+	err = svc.Add(context.Background(), e)
+	if fieldCode(err, "rating") != ErrInvalidRating.Code {
 		t.Fatal("Add() should return error")
 	}
-	e = Entry{Rating: 5}
-	err = svc.Add(e)
+	e = Entry{SessionID: "1", UserID: "1", Rating: 5}
+	err = svc.Add(context.Background(), e)
 	if err != nil {
 		t.Fatal("Add() should not return error")
 	}
@@ -45,7 +48,7 @@ func TestLatest(t *testing.T) {
 		t.Errorf("New() == nil")
 	}
 
-	e, err := svc.GetLatest(0)
+	e, err := svc.GetLatest(context.Background(), 0)
 	if err != nil {
 		t.Fatal("GetLatest() should not return error")
 	}
@@ -82,15 +85,15 @@ func TestService_GetLatestFiltered(t *testing.T) {
 			args{1, 1},
 			func(n uint, f int) ([]Entry, error) {
 				return []Entry{
-					{"1", "1", "1", 1, ""},
-					{"3", "3", "1", 1, ""},
-					{"5", "5", "1", 1, ""},
+					{ID: "1", SessionID: "1", UserID: "1", Rating: 1},
+					{ID: "3", SessionID: "3", UserID: "1", Rating: 1},
+					{ID: "5", SessionID: "5", UserID: "1", Rating: 1},
 				}, nil
 			},
 			[]Entry{
-				{"1", "1", "1", 1, ""},
-				{"3", "3", "1", 1, ""},
-				{"5", "5", "1", 1, ""},
+				{ID: "1", SessionID: "1", UserID: "1", Rating: 1},
+				{ID: "3", SessionID: "3", UserID: "1", Rating: 1},
+				{ID: "5", SessionID: "5", UserID: "1", Rating: 1},
 			},
 			false,
 		},
@@ -98,7 +101,7 @@ func TestService_GetLatestFiltered(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			svc := New(log, newMockRepository(nil, nil, tt.getFunc))
-			got, err := svc.GetLatestFiltered(tt.args.n, tt.args.filter)
+			got, err := svc.GetLatestFiltered(context.Background(), tt.args.n, tt.args.filter)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Service.GetLatestFiltered() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -109,3 +112,254 @@ func TestService_GetLatestFiltered(t *testing.T) {
 		})
 	}
 }
+
+func TestService_GetPage(t *testing.T) {
+	log := log.NewNop()
+	repo := newMockRepository(nil, nil, nil)
+	svc := New(log, repo)
+
+	var gotN uint
+	var gotBefore string
+	repo.getLatestBefore = func(n uint, before string, r Range) ([]Entry, error) {
+		gotN, gotBefore = n, before
+		return []Entry{{ID: "3"}, {ID: "2"}, {ID: "1"}}, nil
+	}
+	page, err := svc.GetPage(context.Background(), 2, encodeCursor("4"), Range{})
+	if err != nil {
+		t.Fatal("GetPage() should not return error", err)
+	}
+	if gotN != 3 || gotBefore != "4" {
+		t.Fatalf("GetPage() requested %d before %q want 3 before %q", gotN, gotBefore, "4")
+	}
+	if len(page.Entries) != 2 || page.Next != encodeCursor("2") {
+		t.Fatalf("GetPage() = %v", page)
+	}
+
+	if _, err := svc.GetPage(context.Background(), 2, "invalid!", Range{}); err != ErrInvalidCursor {
+		t.Fatalf("GetPage() = %v want %v", err, ErrInvalidCursor)
+	}
+}
+
+func TestService_GetPageFiltered(t *testing.T) {
+	log := log.NewNop()
+	repo := newMockRepository(nil, nil, nil)
+	svc := New(log, repo)
+
+	var gotFilter int
+	repo.getLatestFilteredBefore = func(n uint, filter int, before string, r Range) ([]Entry, error) {
+		gotFilter = filter
+		return []Entry{{ID: "3", Rating: 2}}, nil
+	}
+	page, err := svc.GetPageFiltered(context.Background(), 2, 2, "", Range{})
+	if err != nil {
+		t.Fatal("GetPageFiltered() should not return error", err)
+	}
+	if gotFilter != 2 {
+		t.Fatalf("GetPageFiltered() filter = %d want 2", gotFilter)
+	}
+	if len(page.Entries) != 1 || page.Next != "" {
+		t.Fatalf("GetPageFiltered() = %v", page)
+	}
+}
+
+func TestService_GetBySession(t *testing.T) {
+	log := log.NewNop()
+	repo := newMockRepository(nil, nil, nil)
+	svc := New(log, repo)
+
+	if _, err := svc.GetBySession(context.Background(), ""); err != ErrNoSession {
+		t.Fatalf("GetBySession() = %v want %v", err, ErrNoSession)
+	}
+
+	repo.getBySession = func(sessionID string) ([]Entry, error) {
+		return nil, nil
+	}
+	got, err := svc.GetBySession(context.Background(), "1")
+	if err != nil {
+		t.Fatal("GetBySession() should not return error", err)
+	}
+	if got.SessionID != "1" || got.Entries == nil || got.Summary.Count != 0 {
+		t.Fatalf("GetBySession() = %v", got)
+	}
+
+	repo.getBySession = func(sessionID string) ([]Entry, error) {
+		return nil, errors.New("test error")
+	}
+	if _, err := svc.GetBySession(context.Background(), "1"); err == nil {
+		t.Fatal("GetBySession() should return error")
+	}
+}
+
+func TestService_Update(t *testing.T) {
+	log := log.NewNop()
+	var updated Entry
+	repo := newMockRepository(nil, nil, nil)
+	repo.update = func(e Entry) error {
+		updated = e
+		return nil
+	}
+	svc := New(log, repo)
+
+	if err := svc.Update(context.Background(), Entry{SessionID: "1", UserID: "1", Rating: 0}); fieldCode(err, "rating") != ErrInvalidRating.Code {
+		t.Fatalf("Update() = %v want %v", err, ErrInvalidRating)
+	}
+	if err := svc.Update(context.Background(), Entry{SessionID: "1", UserID: "1", Rating: 6}); fieldCode(err, "rating") != ErrInvalidRating.Code {
+		t.Fatalf("Update() = %v want %v", err, ErrInvalidRating)
+	}
+	e := Entry{SessionID: "1", UserID: "1", Rating: 4}
+	if err := svc.Update(context.Background(), e); err != nil {
+		t.Fatal("Update() should not return error", err)
+	}
+	if updated != e {
+		t.Fatalf("Update() passed %v want %v", updated, e)
+	}
+}
+
+func TestService_Get(t *testing.T) {
+	log := log.NewNop()
+	svc := New(log, newMockRepository(nil, nil, nil))
+
+	if _, err := svc.Get(context.Background(), "", "1"); err != ErrNoSession {
+		t.Fatalf("Get() = %v want %v", err, ErrNoSession)
+	}
+	if _, err := svc.Get(context.Background(), "1", ""); err != ErrNoUserID {
+		t.Fatalf("Get() = %v want %v", err, ErrNoUserID)
+	}
+	if _, err := svc.Get(context.Background(), "1", "1"); err != ErrNotFound {
+		t.Fatalf("Get() = %v want %v", err, ErrNotFound)
+	}
+}
+
+// sessionStore keeps sessions in a map
+type sessionStore map[string]Session
+
+func (s sessionStore) PutSession(ctx context.Context, session Session) error {
+	s[session.ID] = session
+	return nil
+}
+
+func (s sessionStore) GetSession(ctx context.Context, id string) (Session, error) {
+	session, ok := s[id]
+	if !ok {
+		return Session{}, ErrUnknownSession
+	}
+	return session, nil
+}
+
+func (s sessionStore) GetParticipation(ctx context.Context, id, userID string) (Session, bool, error) {
+	session, err := s.GetSession(ctx, id)
+	if err != nil {
+		return session, false, err
+	}
+	for _, p := range session.Participants {
+		if p == userID {
+			return session, true, nil
+		}
+	}
+	return session, false, nil
+}
+
+func TestService_AddEligibility(t *testing.T) {
+	start := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	sessions := sessionStore{
+		"running": {ID: "running", Participants: []string{"u1"}, StartedAt: start, RatingWindow: time.Hour},
+		"ended":   {ID: "ended", Participants: []string{"u1"}, StartedAt: start, EndedAt: start.Add(time.Hour), RatingWindow: time.Hour},
+	}
+	tests := []struct {
+		name    string
+		session string
+		user    string
+		now     time.Time
+		want    error
+	}{
+		{"running", "running", "u1", start.Add(5 * time.Hour), nil},
+		{"withinWindow", "ended", "u1", start.Add(90 * time.Minute), nil},
+		{"unknownSession", "unknown", "u1", start, ErrUnknownSession},
+		{"notParticipant", "ended", "u2", start.Add(90 * time.Minute), ErrNotParticipant},
+		{"notStarted", "running", "u1", start.Add(-time.Minute), ErrSessionNotStarted},
+		{"windowClosed", "ended", "u1", start.Add(2 * time.Hour), ErrRatingWindowClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var added, updated bool
+			repo := newMockRepository(func(Entry) error { added = true; return nil }, nil, nil)
+			repo.update = func(Entry) error { updated = true; return nil }
+			svc := New(log.NewNop(), repo)
+			svc.Sessions = sessions
+			svc.now = func() time.Time { return tt.now }
+
+			before := float64(0)
+			if tt.want != nil {
+				before = ineligibleTotal.Value(tt.want.(*Error).Code)
+			}
+			e := Entry{SessionID: tt.session, UserID: tt.user, Rating: 5}
+			if err := svc.Add(context.Background(), e); err != tt.want {
+				t.Fatalf("Add() = %v want %v", err, tt.want)
+			}
+			if err := svc.Update(context.Background(), e); err != tt.want {
+				t.Fatalf("Update() = %v want %v", err, tt.want)
+			}
+			if added != (tt.want == nil) || updated != (tt.want == nil) {
+				t.Errorf("entry stored = %v, %v want %v", added, updated, tt.want == nil)
+			}
+			if tt.want != nil && ineligibleTotal.Value(tt.want.(*Error).Code) != before+2 {
+				t.Errorf("rejections not counted")
+			}
+		})
+	}
+}
+
+func TestService_RegisterSession(t *testing.T) {
+	start := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		session    Session
+		want       []string
+		wantFields map[string]string
+	}{
+		{
+			name:    "normalized",
+			session: Session{ID: "s1", Participants: []string{"u2", "u1", "u2"}, StartedAt: start, RatingWindow: time.Hour},
+			want:    []string{"u1", "u2"},
+		},
+		{
+			name:       "missing",
+			session:    Session{ID: "s1"},
+			wantFields: map[string]string{"participants": "required", "startedAt": "required", "ratingWindow": "invalid_rating_window"},
+		},
+		{
+			name:    "invalid",
+			session: Session{ID: "sessions", Participants: []string{"u 1"}, StartedAt: start, EndedAt: start.Add(-time.Second), RatingWindow: -time.Second},
+			wantFields: map[string]string{
+				"sessionID":    "reserved",
+				"participants": "invalid_characters",
+				"endedAt":      "before_start",
+				"ratingWindow": "invalid_rating_window",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := sessionStore{}
+			svc := New(log.NewNop(), newMockRepository(nil, nil, nil))
+			svc.Sessions = sessions
+
+			got, err := svc.RegisterSession(context.Background(), tt.session)
+			if tt.wantFields != nil {
+				if codes := fieldCodes(err); !reflect.DeepEqual(codes, tt.wantFields) {
+					t.Fatalf("RegisterSession() violations = %v want %v", codes, tt.wantFields)
+				}
+				if len(sessions) > 0 {
+					t.Error("invalid session registered")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("RegisterSession() error", err)
+			}
+			if !reflect.DeepEqual(got.Participants, tt.want) || !reflect.DeepEqual(sessions[tt.session.ID], got) {
+				t.Errorf("RegisterSession() = %+v, stored %+v want participants %v", got, sessions[tt.session.ID], tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package feedback
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Session of a game registered by the game backend. When the session registry is enabled, only its
+// participants can rate it, from its start until RatingWindow after its end.
+type Session struct {
+	ID           string
+	Participants []string
+	StartedAt    time.Time
+	// EndedAt is zero while the session is running
+	EndedAt      time.Time
+	RatingWindow time.Duration
+}
+
+// RatingClosesAt is the end of the rating window, zero while the session is running
+func (s Session) RatingClosesAt() time.Time {
+	if s.EndedAt.IsZero() {
+		return time.Time{}
+	}
+	return s.EndedAt.Add(s.RatingWindow)
+}
+
+// sessionJSON is the representation of a Session in the API, with the rating window in seconds
+type sessionJSON struct {
+	ID             string     `json:"sessionID"`
+	Participants   []string   `json:"participants"`
+	StartedAt      *time.Time `json:"startedAt"`
+	EndedAt        *time.Time `json:"endedAt,omitempty"`
+	RatingWindow   *int64     `json:"ratingWindow"`
+	RatingClosesAt *time.Time `json:"ratingClosesAt,omitempty"`
+}
+
+// MarshalJSON leaving out the end of running sessions
+func (s Session) MarshalJSON() ([]byte, error) {
+	window := int64(s.RatingWindow / time.Second)
+	v := sessionJSON{
+		ID:           s.ID,
+		Participants: s.Participants,
+		StartedAt:    &s.StartedAt,
+		RatingWindow: &window,
+	}
+	if v.Participants == nil {
+		v.Participants = []string{}
+	}
+	if !s.EndedAt.IsZero() {
+		closes := s.RatingClosesAt()
+		v.EndedAt, v.RatingClosesAt = &s.EndedAt, &closes
+	}
+	return json.Marshal(v)
+}
+
+// SessionStore keeps the sessions registered by the game backend
+type SessionStore interface {
+	// PutSession registers s, replacing an earlier registration of its ID
+	PutSession(ctx context.Context, s Session) error
+	// GetSession registered as id, ErrUnknownSession if there is none
+	GetSession(ctx context.Context, id string) (Session, error)
+	// GetParticipation returns the session id without its participants and whether userID takes part in it,
+	// ErrUnknownSession if there is none
+	GetParticipation(ctx context.Context, id, userID string) (Session, bool, error)
+}
+
+// reservedSessionID can't be registered, as its entries would be shadowed by the registry routes
+const reservedSessionID = "sessions"
+
+// RegisterSession in the SessionStore, replacing an earlier registration of its ID
+func (s *Service) RegisterSession(ctx context.Context, session Session) (Session, error) {
+	session.Participants = normalizeParticipants(session.Participants)
+	if err := validateSession(session); err != nil {
+		return session, err
+	}
+	return session, s.Sessions.PutSession(ctx, session)
+}
+
+// GetSession registered as id from the SessionStore
+func (s *Service) GetSession(ctx context.Context, id string) (Session, error) {
+	if len(id) < 1 {
+		return Session{}, ErrNoSession
+	}
+	return s.Sessions.GetSession(ctx, id)
+}
+
+// checkEligible returns why the user of entry may not rate its session now, nil if the registry is disabled
+func (s *Service) checkEligible(ctx context.Context, entry Entry) error {
+	if s.Sessions == nil {
+		return nil
+	}
+	session, participant, err := s.Sessions.GetParticipation(ctx, entry.SessionID, entry.UserID)
+	now := s.now()
+	switch {
+	case err != nil:
+	case !participant:
+		err = ErrNotParticipant
+	case now.Before(session.StartedAt):
+		err = ErrSessionNotStarted
+	case !session.EndedAt.IsZero() && !now.Before(session.RatingClosesAt()):
+		err = ErrRatingWindowClosed
+	}
+	if e, ok := err.(*Error); ok {
+		ineligibleTotal.Inc(e.Code)
+	}
+	return err
+}
+
+// normalizeParticipants sorts participants and drops duplicates
+func normalizeParticipants(participants []string) []string {
+	sorted := append([]string(nil), participants...)
+	sort.Strings(sorted)
+	unique := sorted[:0]
+	for i, p := range sorted {
+		if i == 0 || p != sorted[i-1] {
+			unique = append(unique, p)
+		}
+	}
+	return unique
+}
+
+func validateSession(s Session) error {
+	v := &ValidationError{}
+	validateSessionFields(s, v)
+	return v.err()
+}
+
+func validateSessionFields(s Session, v *ValidationError) {
+	validateID(v, "sessionID", s.ID)
+	if s.ID == reservedSessionID {
+		v.add("sessionID", "reserved", "sessionID "+reservedSessionID+" is reserved")
+	}
+	if len(s.Participants) < 1 {
+		v.add("participants", "required", "participants are required")
+	}
+	for _, p := range s.Participants {
+		validateID(v, "participants", p)
+	}
+	if s.StartedAt.IsZero() {
+		v.add("startedAt", "required", "startedAt is required")
+	}
+	if !s.EndedAt.IsZero() && s.EndedAt.Before(s.StartedAt) {
+		v.add("endedAt", "before_start", "endedAt may not be before startedAt")
+	}
+	if s.RatingWindow <= 0 || s.RatingWindow > maxRatingWindow {
+		v.add("ratingWindow", "invalid_rating_window", "ratingWindow has to be a positive number of seconds, up to a year")
+	}
+}
+
+// decodeSession from a JSON request body, taking its ID from the path and reporting missing fields in v
+func decodeSession(r *http.Request, v *ValidationError) (Session, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxSessionBodySize+1))
+	if err != nil {
+		return Session{}, ErrInvalidBody.Wrap(err)
+	}
+	if len(data) > maxSessionBodySize {
+		return Session{}, ErrBodyTooLarge
+	}
+	var body sessionJSON
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&body); err != nil {
+		return Session{}, ErrInvalidBody.Wrap(err)
+	}
+	s := Session{
+		ID:           mux.Vars(r)["sessionID"],
+		Participants: body.Participants,
+	}
+	if body.StartedAt != nil {
+		s.StartedAt = *body.StartedAt
+	}
+	if body.EndedAt != nil {
+		s.EndedAt = *body.EndedAt
+	}
+	// required, so a forgotten window doesn't close ratings as soon as the session ends
+	if body.RatingWindow == nil {
+		v.add("ratingWindow", "required", "ratingWindow is required")
+	} else {
+		// capped before converting, so windows out of range are rejected instead of overflowing
+		window := *body.RatingWindow
+		if window > int64(maxRatingWindow/time.Second) {
+			window = int64(maxRatingWindow/time.Second) + 1
+		}
+		if window < 0 {
+			window = -1
+		}
+		s.RatingWindow = time.Duration(window) * time.Second
+	}
+	return s, nil
+}
+
+// maxRatingWindow accepted for sessions
+const maxRatingWindow = 365 * 24 * time.Hour
+
+// maxSessionBodySize of session registrations, fitting some thousand participants
+const maxSessionBodySize = 256 << 10
+
+func (s *Service) registerSession(w http.ResponseWriter, r *http.Request) (err error) {
+	defer func() { s.deferError(w, r, err) }()
+
+	v := &ValidationError{}
+	session, err := decodeSession(r, v)
+	if err != nil {
+		return err
+	}
+	// report all violations at once instead of only the ones found while decoding
+	if len(v.Fields) > 0 {
+		validateSessionFields(session, v)
+		return v
+	}
+	session, err = s.RegisterSession(r.Context(), session)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, session)
+}
+
+func (s *Service) getRegisteredSession(w http.ResponseWriter, r *http.Request) (err error) {
+	defer func() { s.deferError(w, r, err) }()
+
+	session, err := s.GetSession(r.Context(), mux.Vars(r)["sessionID"])
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, session)
+}
@@ -0,0 +1,84 @@
+package feedback
+
+// Summary of the ratings of a set of entries
+type Summary struct {
+	Count     int          `json:"count"`
+	Average   float64      `json:"average"`
+	Median    float64      `json:"median"`
+	Histogram map[int8]int `json:"histogram"`
+}
+
+// Stats of all ratings, optionally grouped by session
+type Stats struct {
+	Summary
+	Sessions map[string]Summary `json:"sessions,omitempty"`
+}
+
+// NewStats from rating histograms of all entries and per session, sessions may be nil if not grouped
+func NewStats(total map[int8]int, sessions map[string]map[int8]int) Stats {
+	stats := Stats{Summary: SummarizeHistogram(total)}
+	if sessions != nil {
+		stats.Sessions = make(map[string]Summary, len(sessions))
+		for id, h := range sessions {
+			stats.Sessions[id] = SummarizeHistogram(h)
+		}
+	}
+	return stats
+}
+
+// Summarize ratings of entries
+func Summarize(entries []Entry) Summary {
+	h := make(map[int8]int)
+	for _, e := range entries {
+		h[e.Rating]++
+	}
+	return SummarizeHistogram(h)
+}
+
+// SummarizeHistogram of ratings mapped to their number of occurrences
+func SummarizeHistogram(h map[int8]int) Summary {
+	s := Summary{Histogram: newHistogram()}
+	var sum int
+	for r, n := range h {
+		s.Histogram[r] += n
+		s.Count += n
+		sum += int(r) * n
+	}
+	if s.Count == 0 {
+		return s
+	}
+	s.Average = float64(sum) / float64(s.Count)
+	s.Median = median(s.Histogram, s.Count)
+	return s
+}
+
+// median of count ratings in h, averaging the middle two for even counts
+func median(h map[int8]int, count int) float64 {
+	lower, upper := (count-1)/2, count/2
+	var (
+		seen int
+		lo   int8
+	)
+	for r := int8(minRating); r <= maxRating; r++ {
+		if h[r] == 0 {
+			continue
+		}
+		if seen <= lower && lower < seen+h[r] {
+			lo = r
+		}
+		if seen <= upper && upper < seen+h[r] {
+			return float64(lo+r) / 2
+		}
+		seen += h[r]
+	}
+	return 0
+}
+
+// newHistogram with all valid ratings set to zero
+func newHistogram() map[int8]int {
+	h := make(map[int8]int, maxRating-minRating+1)
+	for r := int8(minRating); r <= maxRating; r++ {
+		h[r] = 0
+	}
+	return h
+}
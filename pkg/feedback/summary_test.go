@@ -0,0 +1,94 @@
+package feedback
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []Entry
+		want    Summary
+	}{
+		{
+			"empty",
+			nil,
+			Summary{
+				Histogram: map[int8]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0},
+			},
+		},
+		{
+			"mixed",
+			[]Entry{{Rating: 1}, {Rating: 5}, {Rating: 5}, {Rating: 3}},
+			Summary{
+				Count:     4,
+				Average:   3.5,
+				Median:    4,
+				Histogram: map[int8]int{1: 1, 2: 0, 3: 1, 4: 0, 5: 2},
+			},
+		},
+		{
+			"odd",
+			[]Entry{{Rating: 1}, {Rating: 2}, {Rating: 5}},
+			Summary{
+				Count:     3,
+				Average:   8.0 / 3,
+				Median:    2,
+				Histogram: map[int8]int{1: 1, 2: 1, 3: 0, 4: 0, 5: 1},
+			},
+		},
+		{
+			"single",
+			[]Entry{{Rating: 4}},
+			Summary{
+				Count:     1,
+				Average:   4,
+				Median:    4,
+				Histogram: map[int8]int{1: 0, 2: 0, 3: 0, 4: 1, 5: 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Summarize(tt.entries); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Summarize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeHistogram(t *testing.T) {
+	tests := []struct {
+		name       string
+		h          map[int8]int
+		wantMedian float64
+	}{
+		{"empty", nil, 0},
+		{"evenSplit", map[int8]int{1: 2, 5: 2}, 3},
+		{"skewed", map[int8]int{1: 10, 4: 1, 5: 1}, 1},
+		{"upperHalf", map[int8]int{2: 1, 3: 2, 5: 3}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SummarizeHistogram(tt.h).Median; got != tt.wantMedian {
+				t.Errorf("SummarizeHistogram().Median = %v, want %v", got, tt.wantMedian)
+			}
+		})
+	}
+}
+
+func TestNewStats(t *testing.T) {
+	stats := NewStats(map[int8]int{1: 1, 5: 1}, nil)
+	if stats.Count != 2 || stats.Sessions != nil {
+		t.Fatalf("NewStats() = %v", stats)
+	}
+
+	stats = NewStats(map[int8]int{1: 1, 5: 1}, map[string]map[int8]int{
+		"a": {1: 1},
+		"b": {5: 1},
+	})
+	if len(stats.Sessions) != 2 || stats.Sessions["a"].Average != 1 || stats.Sessions["b"].Average != 5 {
+		t.Fatalf("NewStats() = %v", stats)
+	}
+}
@@ -0,0 +1,39 @@
+package feedback
+
+import (
+	"context"
+	"net/http"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+)
+
+// trace h in a server span, continuing the trace of the caller if its headers carry one.
+// The span is passed on through the request context.
+func trace(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tracer := opentracing.GlobalTracer()
+		// a missing or malformed parent only starts a new trace
+		parent, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+		span := tracer.StartSpan(r.Method+" "+routeName(r), ext.RPCServerOption(parent))
+		defer span.Finish()
+		ext.HTTPMethod.Set(span, r.Method)
+		ext.HTTPUrl.Set(span, r.URL.String())
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h(rec, r.WithContext(opentracing.ContextWithSpan(r.Context(), span)))
+
+		ext.HTTPStatusCode.Set(span, uint16(rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			ext.Error.Set(span, true)
+		}
+	}
+}
+
+// traceError logs err to the span of ctx
+func traceError(ctx context.Context, err error) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.LogFields(otlog.Error(err))
+	}
+}
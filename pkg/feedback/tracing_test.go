@@ -0,0 +1,72 @@
+package feedback
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/playnet-public/libs/log"
+)
+
+func TestTrace(t *testing.T) {
+	tracer := mocktracer.New()
+	global := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(global)
+
+	var repoSpan opentracing.Span
+	repo := newMockRepository(nil, nil, nil)
+	svc := New(log.NewNop(), &spanRepository{mockRepository: repo, span: &repoSpan})
+
+	caller := tracer.StartSpan("game-backend")
+	r := httptest.NewRequest("GET", "/abc", nil)
+	if err := tracer.Inject(caller.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header)); err != nil {
+		t.Fatal(err)
+	}
+	svc.Handler().ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("finished %d spans want 1", len(spans))
+	}
+	span := spans[0]
+	if span.OperationName != "GET /{sessionID}" {
+		t.Errorf("operation = %q want %q", span.OperationName, "GET /{sessionID}")
+	}
+	if span.ParentID != caller.Context().(mocktracer.MockSpanContext).SpanID {
+		t.Error("request span does not continue the trace of the caller")
+	}
+	if got := span.Tag("http.status_code"); got != uint16(http.StatusOK) {
+		t.Errorf("status tag = %v want %v", got, http.StatusOK)
+	}
+	if repoSpan != opentracing.Span(span) {
+		t.Error("request span is not passed on to the repository")
+	}
+
+	// failing requests without caller start a new trace and log their error
+	tracer.Reset()
+	svc.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/list?limit=x", nil))
+	spans = tracer.FinishedSpans()
+	if len(spans) != 1 || spans[0].ParentID != 0 {
+		t.Fatalf("expected a single root span, got %v", spans)
+	}
+	logs := spans[0].Logs()
+	if len(logs) != 1 || !strings.Contains(logs[0].Fields[0].ValueString, ErrInvalidLimit.Message) {
+		t.Errorf("error not logged to span: %v", logs)
+	}
+}
+
+// spanRepository remembers the span it got called with
+type spanRepository struct {
+	*mockRepository
+	span *opentracing.Span
+}
+
+func (r *spanRepository) GetBySession(ctx context.Context, sessionID string) ([]Entry, error) {
+	*r.span = opentracing.SpanFromContext(ctx)
+	return r.mockRepository.GetBySession(ctx, sessionID)
+}
@@ -0,0 +1,155 @@
+package feedback
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxIDLength of session and user ids, matching the database columns
+	maxIDLength = 50
+	// maxCommentLength in characters, matching the database column
+	maxCommentLength = 50
+)
+
+// FieldError describes why a single field of a request is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError listing all invalid fields of a request
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return ErrValidation.Message + ": " + strings.Join(msgs, ", ")
+}
+
+// add a violation unless field already has one
+func (e *ValidationError) add(field, code, message string) {
+	if e.has(field) {
+		return
+	}
+	e.Fields = append(e.Fields, FieldError{field, code, message})
+}
+
+func (e *ValidationError) has(field string) bool {
+	for _, f := range e.Fields {
+		if f.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// err returns e if it contains any violations
+func (e *ValidationError) err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func validate(entry Entry) error {
+	v := &ValidationError{}
+	validateEntry(entry, v)
+	return v.err()
+}
+
+func validateEntry(entry Entry, v *ValidationError) {
+	validateID(v, "sessionID", entry.SessionID)
+	validateID(v, "userID", entry.UserID)
+	if entry.Rating > maxRating || entry.Rating < minRating {
+		v.add("rating", ErrInvalidRating.Code, ErrInvalidRating.Message)
+	}
+	validateComment(v, entry.Comment)
+}
+
+func validateID(v *ValidationError, field, id string) {
+	if len(id) < 1 {
+		v.add(field, "required", field+" is required")
+		return
+	}
+	if len(id) > maxIDLength {
+		v.add(field, "too_long", fmt.Sprintf("%s may be at most %d characters long", field, maxIDLength))
+		return
+	}
+	for _, r := range id {
+		if !isIDRune(r) {
+			v.add(field, "invalid_characters", field+" may only contain letters, digits and -_.:")
+			return
+		}
+	}
+}
+
+func isIDRune(r rune) bool {
+	return r >= 'a' && r <= 'z' ||
+		r >= 'A' && r <= 'Z' ||
+		r >= '0' && r <= '9' ||
+		strings.ContainsRune("-_.:", r)
+}
+
+func validateComment(v *ValidationError, comment string) {
+	if !utf8.ValidString(comment) {
+		v.add("comment", "invalid_utf8", "comment is not valid UTF-8")
+		return
+	}
+	if utf8.RuneCountInString(comment) > maxCommentLength {
+		v.add("comment", "too_long", fmt.Sprintf("comment may be at most %d characters long", maxCommentLength))
+		return
+	}
+	for _, r := range comment {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			v.add("comment", "invalid_characters", "comment may not contain control characters")
+			return
+		}
+	}
+}
+
+// decodeEntry reads rating and comment from a JSON request body, reporting every field that
+// is unknown, missing or of the wrong type in v
+func decodeEntry(body []byte, v *ValidationError) (entry Entry, err error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return entry, ErrInvalidBody.Wrap(err)
+	}
+	// sorted for a stable order of violations
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		raw := fields[name]
+		switch name {
+		case "rating":
+			if err := json.Unmarshal(raw, &entry.Rating); err != nil {
+				v.add(name, "invalid_type", "rating has to be a number between 1-5")
+			}
+		case "comment":
+			// invalid UTF-8 would be replaced silently while decoding
+			if !utf8.Valid(raw) {
+				v.add(name, "invalid_utf8", "comment is not valid UTF-8")
+			} else if err := json.Unmarshal(raw, &entry.Comment); err != nil {
+				v.add(name, "invalid_type", "comment has to be a string")
+			}
+		default:
+			v.add(name, "unknown_field", "unknown field "+name)
+		}
+	}
+	if _, ok := fields["rating"]; !ok {
+		v.add("rating", "required", "rating is required")
+	}
+	return entry, nil
+}
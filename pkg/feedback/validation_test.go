@@ -0,0 +1,222 @@
+package feedback
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fieldCode returns the violation code of field in err, or "" if there is none
+func fieldCode(err error, field string) string {
+	v, ok := err.(*ValidationError)
+	if !ok {
+		return ""
+	}
+	for _, f := range v.Fields {
+		if f.Field == field {
+			return f.Code
+		}
+	}
+	return ""
+}
+
+func TestValidate(t *testing.T) {
+	valid := Entry{SessionID: "match-42", UserID: "c0ffee:1", Rating: 3, Comment: "gg\nwp"}
+	tests := []struct {
+		name  string
+		entry func(Entry) Entry
+		want  map[string]string
+	}{
+		{
+			"valid",
+			func(e Entry) Entry { return e },
+			nil,
+		},
+		{
+			"emptyComment",
+			func(e Entry) Entry { e.Comment = ""; return e },
+			nil,
+		},
+		{
+			"multibyteComment",
+			func(e Entry) Entry { e.Comment = strings.Repeat("ü", maxCommentLength); return e },
+			nil,
+		},
+		{
+			"missingIDs",
+			func(e Entry) Entry { e.SessionID, e.UserID = "", ""; return e },
+			map[string]string{"sessionID": "required", "userID": "required"},
+		},
+		{
+			"longIDs",
+			func(e Entry) Entry {
+				e.SessionID = strings.Repeat("s", maxIDLength+1)
+				e.UserID = strings.Repeat("u", maxIDLength+1)
+				return e
+			},
+			map[string]string{"sessionID": "too_long", "userID": "too_long"},
+		},
+		{
+			"invalidIDCharacters",
+			func(e Entry) Entry { e.SessionID, e.UserID = "a b", "ä"; return e },
+			map[string]string{"sessionID": "invalid_characters", "userID": "invalid_characters"},
+		},
+		{
+			"ratingTooLow",
+			func(e Entry) Entry { e.Rating = 0; return e },
+			map[string]string{"rating": "invalid_rating"},
+		},
+		{
+			"ratingTooHigh",
+			func(e Entry) Entry { e.Rating = 6; return e },
+			map[string]string{"rating": "invalid_rating"},
+		},
+		{
+			"longComment",
+			func(e Entry) Entry { e.Comment = strings.Repeat("c", maxCommentLength+1); return e },
+			map[string]string{"comment": "too_long"},
+		},
+		{
+			"invalidUTF8Comment",
+			func(e Entry) Entry { e.Comment = "\xff"; return e },
+			map[string]string{"comment": "invalid_utf8"},
+		},
+		{
+			"controlCharacterComment",
+			func(e Entry) Entry { e.Comment = "a\x00b"; return e },
+			map[string]string{"comment": "invalid_characters"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate(tt.entry(valid))
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("validate() = %v want nil", err)
+				}
+				return
+			}
+			if got := fieldCodes(err); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("validate() = %v want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    Entry
+		fields  map[string]string
+		wantErr bool
+	}{
+		{
+			"valid",
+			`{"rating": 4, "comment": "nice"}`,
+			Entry{Rating: 4, Comment: "nice"},
+			nil,
+			false,
+		},
+		{
+			"ratingOnly",
+			`{"rating": 1}`,
+			Entry{Rating: 1},
+			nil,
+			false,
+		},
+		{
+			"malformed",
+			`{"rating"`,
+			Entry{},
+			nil,
+			true,
+		},
+		{
+			"notAnObject",
+			`[1]`,
+			Entry{},
+			nil,
+			true,
+		},
+		{
+			"missingRating",
+			`{"comment": "nice"}`,
+			Entry{Comment: "nice"},
+			map[string]string{"rating": "required"},
+			false,
+		},
+		{
+			"invalidTypes",
+			`{"rating": "5", "comment": 1}`,
+			Entry{},
+			map[string]string{"rating": "invalid_type", "comment": "invalid_type"},
+			false,
+		},
+		{
+			"ratingOverflow",
+			`{"rating": 300}`,
+			Entry{},
+			map[string]string{"rating": "invalid_type"},
+			false,
+		},
+		{
+			"unknownFields",
+			`{"rating": 1, "userID": "someone-else", "stars": 5}`,
+			Entry{Rating: 1},
+			map[string]string{"userID": "unknown_field", "stars": "unknown_field"},
+			false,
+		},
+		{
+			"invalidUTF8",
+			"{\"rating\": 1, \"comment\": \"\xff\"}",
+			Entry{Rating: 1},
+			map[string]string{"comment": "invalid_utf8"},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &ValidationError{}
+			got, err := decodeEntry([]byte(tt.body), v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeEntry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("decodeEntry() = %v, want %v", got, tt.want)
+			}
+			if codes := fieldCodes(v.err()); !reflect.DeepEqual(codes, tt.fields) {
+				t.Errorf("decodeEntry() violations = %v, want %v", codes, tt.fields)
+			}
+		})
+	}
+}
+
+func TestValidationError_Error(t *testing.T) {
+	v := &ValidationError{}
+	v.add("rating", "required", "rating is required")
+	v.add("rating", "invalid_rating", "ignored as rating already has a violation")
+	v.add("comment", "too_long", "comment too long")
+	if len(v.Fields) != 2 {
+		t.Fatalf("add() kept %d violations want 2", len(v.Fields))
+	}
+	want := "request validation failed: rating: rating is required, comment: comment too long"
+	if v.Error() != want {
+		t.Fatalf("Error() = %q want %q", v.Error(), want)
+	}
+}
+
+func fieldCodes(err error) map[string]string {
+	v, ok := err.(*ValidationError)
+	if !ok {
+		return nil
+	}
+	codes := make(map[string]string)
+	for _, f := range v.Fields {
+		codes[f.Field] = f.Code
+	}
+	return codes
+}
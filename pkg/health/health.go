@@ -0,0 +1,174 @@
+// Package health serves liveness and readiness endpoints backed by pluggable checks.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/playnet-public/libs/log"
+	"go.uber.org/zap"
+)
+
+// Checker reports whether a dependency is usable, returning the reason if it is not
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to the Checker interface
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Health keeps the readiness checks of a service
+type Health struct {
+	*log.Logger
+
+	// Timeout for running all checks of a single readiness request
+	Timeout time.Duration
+
+	mu       sync.RWMutex
+	checks   map[string]Checker
+	draining int32
+}
+
+// New Health without any checks
+func New(log *log.Logger) *Health {
+	log = log.WithFields(zap.String("component", "health"))
+	return &Health{
+		Logger:  log,
+		Timeout: 2 * time.Second,
+		checks:  make(map[string]Checker),
+	}
+}
+
+// Register c as readiness check under name, replacing any check registered before
+func (h *Health) Register(name string, c Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = c
+}
+
+// Drain marks the service as not ready, so it stops receiving new requests
+func (h *Health) Drain() {
+	atomic.StoreInt32(&h.draining, 1)
+}
+
+// Draining reports whether Drain has been called
+func (h *Health) Draining() bool {
+	return atomic.LoadInt32(&h.draining) == 1
+}
+
+// Status of a single check
+type Status struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report of a readiness request
+type Report struct {
+	Status   string            `json:"status"`
+	Draining bool              `json:"draining"`
+	Checks   map[string]Status `json:"checks"`
+}
+
+const (
+	statusOK          = "ok"
+	statusFailed      = "failed"
+	statusUnavailable = "unavailable"
+)
+
+// Ready runs all checks concurrently, the service is ready if none of them failed and it is not draining
+func (h *Health) Ready(ctx context.Context) (Report, bool) {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	h.mu.RLock()
+	names := make([]string, 0, len(h.checks))
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Checker, len(names))
+	for i, name := range names {
+		checks[i] = h.checks[name]
+	}
+	h.mu.RUnlock()
+
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c Checker) {
+			defer wg.Done()
+			errs[i] = c.Check(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{
+		Status:   statusOK,
+		Draining: h.Draining(),
+		Checks:   make(map[string]Status, len(checks)),
+	}
+	if report.Draining {
+		report.Status = statusUnavailable
+	}
+	for i, name := range names {
+		if errs[i] != nil {
+			h.Warn("check failed", zap.String("check", name), zap.Error(errs[i]))
+			report.Checks[name] = Status{Status: statusFailed, Error: errs[i].Error()}
+			report.Status = statusUnavailable
+			continue
+		}
+		report.Checks[name] = Status{Status: statusOK}
+	}
+	return report, report.Status == statusOK
+}
+
+// Handler serving /healthz and /readyz
+func (h *Health) Handler() http.Handler {
+	m := http.NewServeMux()
+	m.HandleFunc("/healthz", h.healthz)
+	m.HandleFunc("/readyz", h.readyz)
+	return m
+}
+
+// healthz only reports the process to be alive, dependencies are covered by readyz
+func (h *Health) healthz(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, Status{Status: statusOK})
+}
+
+func (h *Health) readyz(w http.ResponseWriter, r *http.Request) {
+	report, ok := h.Ready(r.Context())
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusServiceUnavailable
+	}
+	h.writeJSON(w, status, report)
+}
+
+func (h *Health) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		h.Error("marshal error", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("content-type", "application/json; charset=utf-8")
+	w.Header().Set("cache-control", "no-cache")
+	w.WriteHeader(status)
+	if _, err := w.Write(data); err != nil {
+		h.Error("write error", zap.Error(err))
+	}
+}
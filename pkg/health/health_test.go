@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/playnet-public/libs/log"
+)
+
+func TestHealth_healthz(t *testing.T) {
+	h := New(log.NewNop())
+	h.Register("failing", CheckerFunc(func(context.Context) error { return errors.New("down") }))
+
+	w := httptest.NewRecorder()
+	h.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("healthz status = %d want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestHealth_readyz(t *testing.T) {
+	ok := CheckerFunc(func(context.Context) error { return nil })
+	failing := CheckerFunc(func(context.Context) error { return errors.New("connection refused") })
+	slow := CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	tests := []struct {
+		name       string
+		checks     map[string]Checker
+		drain      bool
+		wantStatus int
+		want       map[string]string
+	}{
+		{"noChecks", nil, false, http.StatusOK, map[string]string{}},
+		{"ok", map[string]Checker{"database": ok}, false, http.StatusOK, map[string]string{"database": statusOK}},
+		{
+			"failing",
+			map[string]Checker{"database": ok, "migrations": failing},
+			false,
+			http.StatusServiceUnavailable,
+			map[string]string{"database": statusOK, "migrations": statusFailed},
+		},
+		{"timeout", map[string]Checker{"database": slow}, false, http.StatusServiceUnavailable, map[string]string{"database": statusFailed}},
+		{"draining", map[string]Checker{"database": ok}, true, http.StatusServiceUnavailable, map[string]string{"database": statusOK}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(log.NewNop())
+			h.Timeout = 10 * time.Millisecond
+			for name, c := range tt.checks {
+				h.Register(name, c)
+			}
+			if tt.drain {
+				h.Drain()
+			}
+
+			w := httptest.NewRecorder()
+			h.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("readyz status = %d want %d", w.Code, tt.wantStatus)
+			}
+			var report Report
+			if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Draining != tt.drain {
+				t.Errorf("readyz draining = %v want %v", report.Draining, tt.drain)
+			}
+			if len(report.Checks) != len(tt.want) {
+				t.Fatalf("readyz checks = %v want %v", report.Checks, tt.want)
+			}
+			for name, status := range tt.want {
+				got := report.Checks[name]
+				if got.Status != status {
+					t.Errorf("check %q = %q want %q", name, got.Status, status)
+				}
+				if (got.Error != "") != (status == statusFailed) {
+					t.Errorf("check %q error = %q", name, got.Error)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// Package ingest queues feedback submissions and writes them to the store in batches.
+package ingest
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/playnet-public/libs/log"
+	"go.uber.org/zap"
+)
+
+// Batcher is a feedback.Repository able to add multiple entries at once
+type Batcher interface {
+	feedback.Repository
+	// AddBatch returns the outcome of each entry, err is set if none of them could be added
+	AddBatch(ctx context.Context, entries []feedback.Entry) (errs []error, err error)
+}
+
+// Queue decorating a Batcher, collecting entries passed to Add in a bounded queue from which workers
+// insert them in batches. Add still waits for its entry to be written, so duplicates are reported
+// to the caller, but concurrent submissions share a single statement. Entries of callers giving up
+// before their batch is written are dropped, so a failed Add never leaves an entry behind.
+// All other methods are passed on to the Batcher.
+type Queue struct {
+	Batcher
+	*log.Logger
+
+	// Size of the queue, Add fails with feedback.ErrOverloaded once it is full
+	Size int
+	// Workers writing batches concurrently
+	Workers int
+	// BatchSize is the maximum number of entries written at once
+	BatchSize int
+	// Timeout of writing a single batch, unlimited if zero
+	Timeout time.Duration
+
+	mu      sync.RWMutex
+	queue   chan *request
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// states of a request, changing from pending to either writing or cancelled
+const (
+	pending int32 = iota
+	writing
+	cancelled
+)
+
+type request struct {
+	entry feedback.Entry
+	state int32
+	done  chan error
+}
+
+func newRequest(entry feedback.Entry) *request {
+	return &request{entry: entry, done: make(chan error, 1)}
+}
+
+// New Queue in front of repo, which has to be started before use
+func New(log *log.Logger, repo Batcher) *Queue {
+	log = log.WithFields(zap.String("component", "ingest"))
+	return &Queue{
+		Batcher:   repo,
+		Logger:    log,
+		Size:      1000,
+		Workers:   4,
+		BatchSize: 100,
+		Timeout:   10 * time.Second,
+	}
+}
+
+// Start the workers
+func (q *Queue) Start() {
+	q.queue = make(chan *request, q.Size)
+	setQueue(q.queue)
+	for i := 0; i < q.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	q.Info("started", zap.Int("size", q.Size), zap.Int("workers", q.Workers), zap.Int("batchSize", q.BatchSize))
+}
+
+// Close the queue, returning once all queued entries have been written
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	if !q.stopped && q.queue != nil {
+		q.stopped = true
+		close(q.queue)
+	}
+	q.mu.Unlock()
+	q.wg.Wait()
+	return nil
+}
+
+// Add entry to the queue, waiting until it has been written
+func (q *Queue) Add(ctx context.Context, entry feedback.Entry) error {
+	req := newRequest(entry)
+	if !q.enqueue(req) {
+		rejectedTotal.Inc()
+		return feedback.ErrOverloaded
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		if atomic.CompareAndSwapInt32(&req.state, pending, cancelled) {
+			return ctx.Err()
+		}
+		// the batch is already being written, which is bounded by Timeout
+		return <-req.done
+	}
+}
+
+// enqueue req unless the queue is full or stopped
+func (q *Queue) enqueue(req *request) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.stopped || q.queue == nil {
+		return false
+	}
+	select {
+	case q.queue <- req:
+		return true
+	default:
+		return false
+	}
+}
+
+// work writes batches until the queue is closed. A batch holds all entries queued up to BatchSize,
+// so it grows with the load without delaying single submissions.
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for req := range q.queue {
+		batch := []*request{req}
+	fill:
+		for len(batch) < q.BatchSize {
+			select {
+			case req, ok := <-q.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, req)
+			default:
+				break fill
+			}
+		}
+		q.write(batch)
+	}
+}
+
+// write batch, passing the outcome of each entry to its request and skipping cancelled ones
+func (q *Queue) write(batch []*request) {
+	reqs := batch[:0]
+	for _, req := range batch {
+		if atomic.CompareAndSwapInt32(&req.state, pending, writing) {
+			reqs = append(reqs, req)
+		}
+	}
+	batch = reqs
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	if q.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.Timeout)
+		defer cancel()
+	}
+	entries := make([]feedback.Entry, len(batch))
+	for i, req := range batch {
+		entries[i] = req.entry
+	}
+
+	batchSize.Observe(float64(len(batch)))
+	errs, err := q.AddBatch(ctx, entries)
+	if err != nil {
+		q.Error("writing batch failed", zap.Int("entries", len(batch)), zap.Error(err))
+	}
+	for i, req := range batch {
+		if err != nil {
+			req.done <- err
+			continue
+		}
+		req.done <- errs[i]
+	}
+}
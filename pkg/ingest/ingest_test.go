@@ -0,0 +1,179 @@
+package ingest
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback/feedbacktest"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/memory"
+	"github.com/playnet-public/libs/log"
+)
+
+func TestQueue_Repository(t *testing.T) {
+	feedbacktest.TestRepository(t, func(t *testing.T) feedback.Repository {
+		q := New(log.NewNop(), memory.New(log.NewNop()))
+		q.Start()
+		return q
+	})
+}
+
+// gatedBatcher sends the size of each batch to entered once it starts writing it, blocking until gate is closed
+type gatedBatcher struct {
+	*memory.Store
+	entered chan int
+	gate    chan struct{}
+}
+
+func (b *gatedBatcher) AddBatch(ctx context.Context, entries []feedback.Entry) ([]error, error) {
+	b.entered <- len(entries)
+	<-b.gate
+	return b.Store.AddBatch(ctx, entries)
+}
+
+func newGated(size, batchSize int) (*Queue, *gatedBatcher) {
+	b := &gatedBatcher{Store: memory.New(log.NewNop()), entered: make(chan int, 10), gate: make(chan struct{})}
+	q := New(log.NewNop(), b)
+	q.Size = size
+	q.Workers = 1
+	q.BatchSize = batchSize
+	q.Start()
+	return q, b
+}
+
+// mustEnqueue an entry by user without waiting for it to be written
+func mustEnqueue(t *testing.T, q *Queue, user string) *request {
+	t.Helper()
+	req := newRequest(feedback.Entry{SessionID: "s", UserID: user, Rating: 1})
+	if !q.enqueue(req) {
+		t.Fatalf("entry of user %s not queued", user)
+	}
+	return req
+}
+
+func TestQueue_batches(t *testing.T) {
+	q, b := newGated(10, 4)
+
+	// the first entry is taken by the worker right away, the others queue up behind it
+	reqs := []*request{mustEnqueue(t, q, "0")}
+	if n := <-b.entered; n != 1 {
+		t.Fatalf("first batch = %d entries want 1", n)
+	}
+	for i := 1; i < 7; i++ {
+		reqs = append(reqs, mustEnqueue(t, q, strconv.Itoa(i%6)))
+	}
+	close(b.gate)
+
+	var duplicates int
+	for _, req := range reqs {
+		switch err := <-req.done; err {
+		case nil:
+		case feedback.ErrDuplicateEntry:
+			duplicates++
+		default:
+			t.Fatal(err)
+		}
+	}
+	if duplicates != 1 {
+		t.Errorf("%d duplicates reported want 1", duplicates)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	close(b.entered)
+	var batches []int
+	for n := range b.entered {
+		batches = append(batches, n)
+	}
+	if want := []int{4, 2}; !reflect.DeepEqual(batches, want) {
+		t.Errorf("batches after the first = %v want %v", batches, want)
+	}
+}
+
+func TestQueue_overloaded(t *testing.T) {
+	q, b := newGated(1, 1)
+	ctx := context.Background()
+
+	// the worker holds the first entry while the second fills the queue
+	first := mustEnqueue(t, q, "0")
+	<-b.entered
+	second := mustEnqueue(t, q, "1")
+	if err := q.Add(ctx, feedback.Entry{SessionID: "s", UserID: "2", Rating: 1}); err != feedback.ErrOverloaded {
+		t.Fatalf("Add() to a full queue = %v want %v", err, feedback.ErrOverloaded)
+	}
+	if got := rejectedTotal.Value(); got < 1 {
+		t.Errorf("rejections = %v", got)
+	}
+
+	close(b.gate)
+	for _, req := range []*request{first, second} {
+		if err := <-req.done; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestQueue_cancelled(t *testing.T) {
+	q, b := newGated(10, 10)
+	mustEnqueue(t, q, "0")
+	<-b.entered
+
+	// callers giving up while their entry is queued drop it, so retries don't fail as duplicates
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := q.Add(ctx, feedback.Entry{SessionID: "s", UserID: "1", Rating: 1}); err != context.Canceled {
+		t.Fatalf("Add() = %v want %v", err, context.Canceled)
+	}
+
+	// callers giving up once their entry is being written get its outcome
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- q.Add(ctx, feedback.Entry{SessionID: "s", UserID: "2", Rating: 1})
+	}()
+	close(b.gate)
+	if n := <-b.entered; n != 1 {
+		t.Fatalf("batch = %d entries want only the one not cancelled", n)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Add() of written entry = %v", err)
+	}
+
+	if err := q.Add(context.Background(), feedback.Entry{SessionID: "s", UserID: "1", Rating: 1}); err != nil {
+		t.Fatalf("retried Add() = %v", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueue_Close(t *testing.T) {
+	q, b := newGated(10, 10)
+	mustEnqueue(t, q, "0")
+	<-b.entered
+	mustEnqueue(t, q, "1")
+	mustEnqueue(t, q, "2")
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- q.Close()
+	}()
+	close(b.gate)
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+	entries, err := b.GetBySession(context.Background(), "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("%d entries written before closing want 3", len(entries))
+	}
+	if err := q.Add(context.Background(), feedback.Entry{SessionID: "s", UserID: "4", Rating: 1}); err != feedback.ErrOverloaded {
+		t.Errorf("Add() after Close() = %v want %v", err, feedback.ErrOverloaded)
+	}
+}
@@ -0,0 +1,42 @@
+package ingest
+
+import (
+	"sync"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/metrics"
+)
+
+// queued requests of the latest started Queue, reported through the queue length metric
+var queued struct {
+	sync.Mutex
+	queue chan *request
+}
+
+func setQueue(queue chan *request) {
+	queued.Lock()
+	queued.queue = queue
+	queued.Unlock()
+}
+
+func queueLength() float64 {
+	queued.Lock()
+	defer queued.Unlock()
+	return float64(len(queued.queue))
+}
+
+var (
+	_ = metrics.NewGaugeFunc(
+		"feedback_ingest_queue_length",
+		"Submissions waiting to be written.",
+		queueLength,
+	)
+	rejectedTotal = metrics.NewCounterVec(
+		"feedback_ingest_rejections_total",
+		"Submissions rejected because the queue was full.",
+	)
+	batchSize = metrics.NewHistogramVec(
+		"feedback_ingest_batch_size",
+		"Entries written per batch.",
+		[]float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000},
+	)
+)
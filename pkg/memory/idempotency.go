@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+)
+
+type idempotencyKey struct {
+	user, key string
+}
+
+type idempotencyRecord struct {
+	feedback.IdempotencyRecord
+	createdAt time.Time
+	// reservedAt by the request currently holding the key
+	reservedAt time.Time
+}
+
+// Reserve key for a request with the fingerprint hash, returning the record of the first request if it is taken.
+// Reservations in progress for longer than lease are taken over.
+func (s *Store) Reserve(ctx context.Context, userID, key, hash string, lease time.Duration) (feedback.IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := idempotencyKey{userID, key}
+	now := s.now()
+	rec, ok := s.idempotency[k]
+	if !ok {
+		s.idempotency[k] = &idempotencyRecord{feedback.IdempotencyRecord{Hash: hash}, now, now}
+		return feedback.IdempotencyRecord{}, true, nil
+	}
+	if rec.Response == nil && lease > 0 && now.Sub(rec.reservedAt) > lease {
+		rec.Hash, rec.reservedAt = hash, now
+		return feedback.IdempotencyRecord{}, true, nil
+	}
+	return rec.IdempotencyRecord, false, nil
+}
+
+// Complete the reservation of key, storing the response to replay
+func (s *Store) Complete(ctx context.Context, userID, key string, resp feedback.Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.idempotency[idempotencyKey{userID, key}]; ok {
+		resp.Body = append([]byte(nil), resp.Body...)
+		rec.Response = &resp
+	}
+	return nil
+}
+
+// Release the reservation of key unless it has been completed
+func (s *Store) Release(ctx context.Context, userID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := idempotencyKey{userID, key}
+	if rec, ok := s.idempotency[k]; ok && rec.Response == nil {
+		delete(s.idempotency, k)
+	}
+	return nil
+}
+
+// ExpireIdempotencyKeys deletes idempotency keys older than maxAge every maxAge until ctx is done
+func (s *Store) ExpireIdempotencyKeys(ctx context.Context, maxAge time.Duration) {
+	t := time.NewTicker(maxAge)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		s.mu.Lock()
+		for k, rec := range s.idempotency {
+			if s.now().Sub(rec.createdAt) > maxAge {
+				delete(s.idempotency, k)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
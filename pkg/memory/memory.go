@@ -0,0 +1,219 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/playnet-public/libs/log"
+	"go.uber.org/zap"
+)
+
+// Store implementing the feedback.Repository interface in memory
+type Store struct {
+	*log.Logger
+
+	mu     sync.RWMutex
+	lastID uint64
+	// entries ordered by id, entries[i] has the id i+1
+	entries []feedback.Entry
+	// keys maps session and user to the index of their entry
+	keys map[key]int
+	// idempotency keys of submissions, see feedback.IdempotencyStore
+	idempotency map[idempotencyKey]*idempotencyRecord
+	// sessions registered by id, see feedback.SessionStore
+	sessions map[string]*registeredSession
+
+	now func() time.Time
+}
+
+type key struct {
+	session, user string
+}
+
+// New in-memory store
+func New(log *log.Logger) *Store {
+	log = log.WithFields(zap.String("component", "memory"))
+	return &Store{
+		Logger:      log,
+		keys:        make(map[key]int),
+		idempotency: make(map[idempotencyKey]*idempotencyRecord),
+		sessions:    make(map[string]*registeredSession),
+		now:         time.Now,
+	}
+}
+
+// Add feedback entry to the store
+func (s *Store) Add(ctx context.Context, entry feedback.Entry) error {
+	s.Debug("adding entry",
+		zap.String("session", entry.SessionID),
+		zap.String("user", entry.UserID),
+	)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key{entry.SessionID, entry.UserID}
+	if _, ok := s.keys[k]; ok {
+		return feedback.ErrDuplicateEntry
+	}
+	s.keys[k] = len(s.entries)
+
+	s.lastID++
+	entry.ID = strconv.FormatUint(s.lastID, 10)
+	entry.CreatedAt = s.now()
+	entry.UpdatedAt = entry.CreatedAt
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+// AddBatch adds entries one by one, returning the error of each
+func (s *Store) AddBatch(ctx context.Context, entries []feedback.Entry) ([]error, error) {
+	errs := make([]error, len(entries))
+	for i, e := range entries {
+		errs[i] = s.Add(ctx, e)
+	}
+	return errs, nil
+}
+
+// Update rating and comment of an existing entry in the store
+func (s *Store) Update(ctx context.Context, entry feedback.Entry) error {
+	s.Debug("updating entry",
+		zap.String("session", entry.SessionID),
+		zap.String("user", entry.UserID),
+	)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.keys[key{entry.SessionID, entry.UserID}]
+	if !ok {
+		return feedback.ErrNotFound
+	}
+	s.entries[i].Rating = entry.Rating
+	s.entries[i].Comment = entry.Comment
+	s.entries[i].UpdatedAt = s.now()
+	return nil
+}
+
+// Get the entry of a user for a session from the store
+func (s *Store) Get(ctx context.Context, sessionID, userID string) (feedback.Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, ok := s.keys[key{sessionID, userID}]
+	if !ok {
+		return feedback.Entry{}, feedback.ErrNotFound
+	}
+	return s.entries[i], nil
+}
+
+// GetLatest n entries from the store
+func (s *Store) GetLatest(ctx context.Context, n uint) ([]feedback.Entry, error) {
+	return s.latest(n, func(feedback.Entry) bool { return true }), nil
+}
+
+// GetLatestFiltered n entries by rating from the store
+func (s *Store) GetLatestFiltered(ctx context.Context, n uint, filter int) ([]feedback.Entry, error) {
+	return s.latest(n, func(e feedback.Entry) bool { return int(e.Rating) == filter }), nil
+}
+
+// GetLatestInRange n entries created within r from the store
+func (s *Store) GetLatestInRange(ctx context.Context, n uint, r feedback.Range) ([]feedback.Entry, error) {
+	return s.latest(n, func(e feedback.Entry) bool { return r.Contains(e.CreatedAt) }), nil
+}
+
+// GetLatestFilteredInRange n entries by rating created within r from the store
+func (s *Store) GetLatestFilteredInRange(ctx context.Context, n uint, filter int, r feedback.Range) ([]feedback.Entry, error) {
+	return s.latest(n, func(e feedback.Entry) bool {
+		return int(e.Rating) == filter && r.Contains(e.CreatedAt)
+	}), nil
+}
+
+// GetLatestBefore n entries created within r and older than the entry with id before
+func (s *Store) GetLatestBefore(ctx context.Context, n uint, before string, r feedback.Range) ([]feedback.Entry, error) {
+	start, err := s.start(before)
+	if err != nil {
+		return nil, err
+	}
+	return s.latestFrom(start, n, func(e feedback.Entry) bool { return r.Contains(e.CreatedAt) }), nil
+}
+
+// GetLatestFilteredBefore n entries by rating created within r and older than the entry with id before
+func (s *Store) GetLatestFilteredBefore(ctx context.Context, n uint, filter int, before string, r feedback.Range) ([]feedback.Entry, error) {
+	start, err := s.start(before)
+	if err != nil {
+		return nil, err
+	}
+	return s.latestFrom(start, n, func(e feedback.Entry) bool {
+		return int(e.Rating) == filter && r.Contains(e.CreatedAt)
+	}), nil
+}
+
+// GetBySession returns all entries of a session
+func (s *Store) GetBySession(ctx context.Context, sessionID string) ([]feedback.Entry, error) {
+	return s.latest(^uint(0), func(e feedback.Entry) bool { return e.SessionID == sessionID }), nil
+}
+
+// GetStats aggregates all entries created within r
+func (s *Store) GetStats(ctx context.Context, r feedback.Range, bySession bool) (feedback.Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	total := make(map[int8]int)
+	var sessions map[string]map[int8]int
+	if bySession {
+		sessions = make(map[string]map[int8]int)
+	}
+	for _, e := range s.entries {
+		if !r.Contains(e.CreatedAt) {
+			continue
+		}
+		total[e.Rating]++
+		if bySession {
+			if sessions[e.SessionID] == nil {
+				sessions[e.SessionID] = make(map[int8]int)
+			}
+			sessions[e.SessionID][e.Rating]++
+		}
+	}
+
+	return feedback.NewStats(total, sessions), nil
+}
+
+// start returns the number of entries older than the entry with id before
+func (s *Store) start(before string) (uint64, error) {
+	if before == "" {
+		return math.MaxUint64, nil
+	}
+	id, err := strconv.ParseUint(before, 10, 64)
+	if err != nil {
+		return 0, feedback.ErrInvalidCursor
+	}
+	if id == 0 {
+		return 0, nil
+	}
+	return id - 1, nil
+}
+
+func (s *Store) latest(n uint, match func(feedback.Entry) bool) []feedback.Entry {
+	return s.latestFrom(math.MaxUint64, n, match)
+}
+
+// latestFrom returns up to n matching entries, newest first, out of the first start entries
+func (s *Store) latestFrom(start uint64, n uint, match func(feedback.Entry) bool) []feedback.Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if start > uint64(len(s.entries)) {
+		start = uint64(len(s.entries))
+	}
+	var entries []feedback.Entry
+	for i := int(start) - 1; i >= 0 && uint(len(entries)) < n; i-- {
+		if match(s.entries[i]) {
+			entries = append(entries, s.entries[i])
+		}
+	}
+	return entries
+}
@@ -0,0 +1,285 @@
+package memory
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback/feedbacktest"
+	"github.com/playnet-public/libs/log"
+)
+
+func TestStore_Repository(t *testing.T) {
+	feedbacktest.TestRepository(t, func(t *testing.T) feedback.Repository {
+		return New(log.NewNop())
+	})
+}
+
+func TestStore_Add(t *testing.T) {
+	s := New(log.NewNop())
+
+	if err := s.Add(context.Background(), feedback.Entry{SessionID: "1", UserID: "1", Rating: 1}); err != nil {
+		t.Fatal("Add() should not return error", err)
+	}
+	if err := s.Add(context.Background(), feedback.Entry{SessionID: "1", UserID: "2", Rating: 1}); err != nil {
+		t.Fatal("Add() should not return error", err)
+	}
+	if err := s.Add(context.Background(), feedback.Entry{SessionID: "1", UserID: "1", Rating: 3}); err != feedback.ErrDuplicateEntry {
+		t.Fatalf("Add() = %v want %v", err, feedback.ErrDuplicateEntry)
+	}
+}
+
+func TestStore_AddConcurrent(t *testing.T) {
+	s := New(log.NewNop())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.Add(context.Background(), feedback.Entry{SessionID: "1", UserID: "1", Rating: 1})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var ok int
+	for err := range errs {
+		if err == nil {
+			ok++
+		}
+	}
+	if ok != 1 {
+		t.Fatalf("Add() succeeded %d times want 1", ok)
+	}
+}
+
+var testTime = time.Date(2018, 3, 17, 20, 0, 0, 0, time.UTC)
+
+func newTestStore() *Store {
+	s := New(log.NewNop())
+	s.now = func() time.Time { return testTime }
+	return s
+}
+
+func TestStore_GetLatest(t *testing.T) {
+	s := newTestStore()
+	for _, e := range []feedback.Entry{
+		{SessionID: "1", UserID: "1", Rating: 1},
+		{SessionID: "2", UserID: "1", Rating: 2},
+		{SessionID: "3", UserID: "1", Rating: 1},
+	} {
+		if err := s.Add(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		n    uint
+		want []feedback.Entry
+	}{
+		{
+			"empty",
+			0,
+			nil,
+		},
+		{
+			"limited",
+			2,
+			[]feedback.Entry{
+				{ID: "3", SessionID: "3", UserID: "1", Rating: 1, CreatedAt: testTime, UpdatedAt: testTime},
+				{ID: "2", SessionID: "2", UserID: "1", Rating: 2, CreatedAt: testTime, UpdatedAt: testTime},
+			},
+		},
+		{
+			"all",
+			15,
+			[]feedback.Entry{
+				{ID: "3", SessionID: "3", UserID: "1", Rating: 1, CreatedAt: testTime, UpdatedAt: testTime},
+				{ID: "2", SessionID: "2", UserID: "1", Rating: 2, CreatedAt: testTime, UpdatedAt: testTime},
+				{ID: "1", SessionID: "1", UserID: "1", Rating: 1, CreatedAt: testTime, UpdatedAt: testTime},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.GetLatest(context.Background(), tt.n)
+			if err != nil {
+				t.Fatal("GetLatest() should not return error", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetLatest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_GetLatestFiltered(t *testing.T) {
+	s := newTestStore()
+	for _, e := range []feedback.Entry{
+		{SessionID: "1", UserID: "1", Rating: 1},
+		{SessionID: "2", UserID: "1", Rating: 2},
+		{SessionID: "3", UserID: "1", Rating: 1},
+	} {
+		if err := s.Add(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		n      uint
+		filter int
+		want   []feedback.Entry
+	}{
+		{
+			"noMatch",
+			15,
+			5,
+			nil,
+		},
+		{
+			"filterBy1",
+			15,
+			1,
+			[]feedback.Entry{
+				{ID: "3", SessionID: "3", UserID: "1", Rating: 1, CreatedAt: testTime, UpdatedAt: testTime},
+				{ID: "1", SessionID: "1", UserID: "1", Rating: 1, CreatedAt: testTime, UpdatedAt: testTime},
+			},
+		},
+		{
+			"limited",
+			1,
+			1,
+			[]feedback.Entry{
+				{ID: "3", SessionID: "3", UserID: "1", Rating: 1, CreatedAt: testTime, UpdatedAt: testTime},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.GetLatestFiltered(context.Background(), tt.n, tt.filter)
+			if err != nil {
+				t.Fatal("GetLatestFiltered() should not return error", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetLatestFiltered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_GetLatestFilteredInRange(t *testing.T) {
+	s := New(log.NewNop())
+	for i, e := range []feedback.Entry{
+		{SessionID: "1", UserID: "1", Rating: 1},
+		{SessionID: "2", UserID: "1", Rating: 2},
+		{SessionID: "3", UserID: "1", Rating: 1},
+	} {
+		created := testTime.Add(time.Duration(i) * time.Hour)
+		s.now = func() time.Time { return created }
+		if err := s.Add(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter int
+		r      feedback.Range
+		want   []string
+	}{
+		{
+			"unbounded",
+			1,
+			feedback.Range{},
+			[]string{"3", "1"},
+		},
+		{
+			"since",
+			1,
+			feedback.Range{Since: testTime.Add(time.Hour)},
+			[]string{"3"},
+		},
+		{
+			"until",
+			1,
+			feedback.Range{Until: testTime.Add(2 * time.Hour)},
+			[]string{"1"},
+		},
+		{
+			"between",
+			2,
+			feedback.Range{Since: testTime, Until: testTime.Add(2 * time.Hour)},
+			[]string{"2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.GetLatestFilteredInRange(context.Background(), 15, tt.filter, tt.r)
+			if err != nil {
+				t.Fatal("GetLatestFilteredInRange() should not return error", err)
+			}
+			var ids []string
+			for _, e := range got {
+				ids = append(ids, e.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("GetLatestFilteredInRange() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_Update(t *testing.T) {
+	s := newTestStore()
+	if err := s.Add(context.Background(), feedback.Entry{SessionID: "1", UserID: "1", Rating: 1, Comment: "typo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := testTime.Add(time.Minute)
+	s.now = func() time.Time { return updated }
+	if err := s.Update(context.Background(), feedback.Entry{SessionID: "1", UserID: "1", Rating: 5, Comment: "great"}); err != nil {
+		t.Fatal("Update() should not return error", err)
+	}
+	if err := s.Update(context.Background(), feedback.Entry{SessionID: "1", UserID: "2", Rating: 5}); err != feedback.ErrNotFound {
+		t.Fatalf("Update() = %v want %v", err, feedback.ErrNotFound)
+	}
+
+	got, err := s.Get(context.Background(), "1", "1")
+	if err != nil {
+		t.Fatal("Get() should not return error", err)
+	}
+	want := feedback.Entry{
+		ID:        "1",
+		SessionID: "1",
+		UserID:    "1",
+		Rating:    5,
+		Comment:   "great",
+		CreatedAt: testTime,
+		UpdatedAt: updated,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Get() = %v, want %v", got, want)
+	}
+	if _, err := s.Get(context.Background(), "1", "2"); err != feedback.ErrNotFound {
+		t.Fatalf("Get() = %v want %v", err, feedback.ErrNotFound)
+	}
+}
+
+func TestStore_IdempotencyStore(t *testing.T) {
+	feedbacktest.TestIdempotencyStore(t, func(t *testing.T) feedback.IdempotencyStore {
+		return New(log.NewNop())
+	})
+}
+
+func TestStore_SessionStore(t *testing.T) {
+	feedbacktest.TestSessionStore(t, func(t *testing.T) feedback.SessionStore {
+		return New(log.NewNop())
+	})
+}
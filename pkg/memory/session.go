@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/feedback"
+)
+
+// registeredSession keeps the participants of a session as a set as well
+type registeredSession struct {
+	feedback.Session
+	participants map[string]bool
+}
+
+// PutSession registers session, replacing an earlier registration of its ID
+func (s *Store) PutSession(ctx context.Context, session feedback.Session) error {
+	session.Participants = append([]string(nil), session.Participants...)
+	participants := make(map[string]bool, len(session.Participants))
+	for _, p := range session.Participants {
+		participants[p] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session.ID] = &registeredSession{session, participants}
+	return nil
+}
+
+// GetSession registered as id
+func (s *Store) GetSession(ctx context.Context, id string) (feedback.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rs, ok := s.sessions[id]
+	if !ok {
+		return feedback.Session{}, feedback.ErrUnknownSession
+	}
+	session := rs.Session
+	session.Participants = append([]string(nil), session.Participants...)
+	return session, nil
+}
+
+// GetParticipation returns the session id without its participants and whether userID takes part in it
+func (s *Store) GetParticipation(ctx context.Context, id, userID string) (feedback.Session, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rs, ok := s.sessions[id]
+	if !ok {
+		return feedback.Session{}, false, feedback.ErrUnknownSession
+	}
+	session := rs.Session
+	session.Participants = nil
+	return session, rs.participants[userID], nil
+}
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_requests_total", "Requests by code.", "code")
+	c.Inc("200")
+	c.Add(2, "500")
+	c.Inc("200")
+	r.NewGaugeFunc("test_open", "Open things.", func() float64 { return 3 })
+	r.NewCounterFunc("test_closed_total", "Closed things.", func() float64 { return 7 })
+	h := r.NewHistogramVec("test_duration_seconds", "Duration with \"quotes\"\nand newline.", []float64{0.1, 1}, "route")
+	h.Observe(0.05, `/a"b`)
+	h.Observe(0.5, `/a"b`)
+	h.Observe(5, `/a"b`)
+
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	if err != nil {
+		t.Fatal("WriteTo() error", err)
+	}
+	if int(n) != buf.Len() {
+		t.Fatalf("WriteTo() = %d want %d", n, buf.Len())
+	}
+	want := `# HELP test_closed_total Closed things.
+# TYPE test_closed_total counter
+test_closed_total 7
+# HELP test_duration_seconds Duration with "quotes"\nand newline.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/a\"b",le="0.1"} 1
+test_duration_seconds_bucket{route="/a\"b",le="1"} 2
+test_duration_seconds_bucket{route="/a\"b",le="+Inf"} 3
+test_duration_seconds_sum{route="/a\"b"} 5.55
+test_duration_seconds_count{route="/a\"b"} 3
+# HELP test_open Open things.
+# TYPE test_open gauge
+test_open 3
+# HELP test_requests_total Requests by code.
+# TYPE test_requests_total counter
+test_requests_total{code="200"} 2
+test_requests_total{code="500"} 2
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("WriteTo() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCounterVec(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_total", "Test.")
+	c.Inc()
+	c.Inc()
+	if v := c.Value(); v != 2 {
+		t.Fatalf("Value() = %v want 2", v)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Add() with negative value should panic")
+		}
+	}()
+	c.Add(-1)
+}
+
+func TestCounterVec_labelMismatch(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_total", "Test.", "a", "b")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Inc() with wrong label count should panic")
+		}
+	}()
+	c.Inc("a")
+}
+
+func TestRegistry_duplicate(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("test_total", "Test.")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering a duplicate metric should panic")
+		}
+	}()
+	r.NewHistogramVec("test_total", "Test.", nil)
+}
+
+func TestHistogramVec_Count(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("test_seconds", "Test.", nil, "op")
+	h.Observe(0.2, "add")
+	h.Observe(0.3, "add")
+	if c := h.Count("add"); c != 2 {
+		t.Fatalf("Count() = %d want 2", c)
+	}
+	if c := h.Count("get"); c != 0 {
+		t.Fatalf("Count() = %d want 0", c)
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("test_total", "Test.").Inc()
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("content-type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Handler() content-type = %q", ct)
+	}
+	if !strings.Contains(w.Body.String(), "test_total 1\n") {
+		t.Fatalf("Handler() body = %q", w.Body.String())
+	}
+}
@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemory_Take(t *testing.T) {
+	m := NewMemory()
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+	l := Limit{Burst: 3, Period: 3 * time.Second}
+
+	take := func(key string) Decision {
+		d, err := m.Take(ctx, key, l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	for i := 2; i >= 0; i-- {
+		if d := take("a"); !d.Allowed || d.Remaining != i {
+			t.Fatalf("Take() = %+v want allowed with %d remaining", d, i)
+		}
+	}
+	d := take("a")
+	if d.Allowed || d.RetryAfter != 2*time.Second {
+		t.Fatalf("Take() on empty bucket = %+v", d)
+	}
+	if d := take("b"); !d.Allowed {
+		t.Fatal("buckets not separated by key")
+	}
+
+	now = now.Add(d.RetryAfter)
+	if d := take("a"); !d.Allowed {
+		t.Fatalf("Take() after RetryAfter = %+v", d)
+	}
+
+	// full buckets are dropped
+	now = now.Add(time.Hour)
+	take("c")
+	if _, ok := m.buckets["a"]; ok {
+		t.Fatal("full bucket not dropped")
+	}
+}
@@ -0,0 +1,99 @@
+// Package ratelimit implements token buckets limiting how often a key, like a player or client, may do something.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Limit of Burst requests per Period. A bucket holds up to Burst tokens and is refilled
+// continuously at Burst tokens per Period, every request taking one token.
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// ParseLimit from the form "<burst>/<period>", e.g. "20/1m". An empty string is the zero Limit.
+func ParseLimit(s string) (Limit, error) {
+	if s == "" {
+		return Limit{}, nil
+	}
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, errors.Errorf("limit %q has to be of the form <burst>/<period>", s)
+	}
+	burst, err := strconv.Atoi(parts[0])
+	if err != nil || burst < 1 {
+		return Limit{}, errors.Errorf("limit %q: burst has to be a positive integer", s)
+	}
+	period, err := time.ParseDuration(parts[1])
+	if err != nil || period <= 0 {
+		return Limit{}, errors.Errorf("limit %q: period has to be a positive duration", s)
+	}
+	return Limit{Burst: burst, Period: period}, nil
+}
+
+// IsZero reports whether l doesn't limit anything
+func (l Limit) IsZero() bool {
+	return l.Burst == 0
+}
+
+// Rate of refilled tokens per second
+func (l Limit) Rate() float64 {
+	return float64(l.Burst) / l.Period.Seconds()
+}
+
+func (l Limit) String() string {
+	return strconv.Itoa(l.Burst) + "/" + l.Period.String()
+}
+
+// Decision about a single request
+type Decision struct {
+	Allowed bool
+	Limit   Limit
+	// Remaining requests allowed right away
+	Remaining int
+	// RetryAfter is the time until the next request will be allowed, zero if Allowed
+	RetryAfter time.Duration
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+}
+
+// Store of buckets
+type Store interface {
+	// Take a token from the bucket of key, limited by l
+	Take(ctx context.Context, key string, l Limit) (Decision, error)
+}
+
+// Take a token from a bucket of l which held tokens elapsed ago, returning the tokens left.
+// Denied requests take a token as well, so clients ignoring the limit stay limited, but a bucket never
+// drops below -1 token, so the RetryAfter of a denial always holds.
+func Take(l Limit, tokens float64, elapsed time.Duration) float64 {
+	refilled := math.Min(float64(l.Burst), tokens+elapsed.Seconds()*l.Rate())
+	return math.Max(-1, refilled-1)
+}
+
+// Decide about a request that left tokens in its bucket of l
+func Decide(l Limit, tokens float64) Decision {
+	rate := l.Rate()
+	d := Decision{
+		Allowed: tokens >= 0,
+		Limit:   l,
+		Reset:   seconds((float64(l.Burst) - tokens) / rate),
+	}
+	if d.Allowed {
+		d.Remaining = int(tokens)
+	} else {
+		d.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return d
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{"", Limit{}, false},
+		{"20/1m", Limit{20, time.Minute}, false},
+		{"1/500ms", Limit{1, 500 * time.Millisecond}, false},
+		{"20", Limit{}, true},
+		{"0/1m", Limit{}, true},
+		{"x/1m", Limit{}, true},
+		{"20/0s", Limit{}, true},
+		{"20/minute", Limit{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLimit(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLimit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseLimit() = %v want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTake(t *testing.T) {
+	l := Limit{Burst: 2, Period: 2 * time.Second}
+	tests := []struct {
+		name        string
+		tokens      float64
+		elapsed     time.Duration
+		want        float64
+		wantAllowed bool
+		wantRetry   time.Duration
+	}{
+		{"full", 2, 0, 1, true, 0},
+		{"last", 1, 0, 0, true, 0},
+		{"empty", 0, 0, -1, false, 2 * time.Second},
+		{"refilled", 0, time.Second, 0, true, 0},
+		{"capped", 2, time.Hour, 1, true, 0},
+		{"floored", -1, 0, -1, false, 2 * time.Second},
+		{"partly", -1, 1500 * time.Millisecond, -0.5, false, 1500 * time.Millisecond},
+		{"retried", -1, 2 * time.Second, 0, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Take(l, tt.tokens, tt.elapsed)
+			if got != tt.want {
+				t.Fatalf("Take() = %v want %v", got, tt.want)
+			}
+			d := Decide(l, got)
+			if d.Allowed != tt.wantAllowed || d.RetryAfter != tt.wantRetry {
+				t.Fatalf("Decide() = %+v want allowed %v retry after %v", d, tt.wantAllowed, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestDecide(t *testing.T) {
+	l := Limit{Burst: 10, Period: 10 * time.Second}
+	d := Decide(l, 3.5)
+	if !d.Allowed || d.Remaining != 3 || d.Reset != 6500*time.Millisecond {
+		t.Fatalf("Decide() = %+v", d)
+	}
+}
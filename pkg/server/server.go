@@ -0,0 +1,102 @@
+// Package server runs the http server until shutdown, draining in-flight requests before releasing resources.
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/health"
+	"github.com/playnet-public/libs/log"
+	"go.uber.org/zap"
+)
+
+// Server serving a handler until its context is done
+type Server struct {
+	*log.Logger
+	srv *http.Server
+
+	// Health is marked as draining once the shutdown starts
+	Health *health.Health
+	// DrainDelay to wait after reporting not ready, giving load balancers time to stop sending requests
+	DrainDelay time.Duration
+	// GracePeriod in-flight requests get to finish before their connections are closed
+	GracePeriod time.Duration
+	// Closers are closed in order after the last request has been served
+	Closers []io.Closer
+}
+
+// New Server listening on addr
+func New(log *log.Logger, addr string, h http.Handler) *Server {
+	log = log.WithFields(zap.String("component", "server"))
+	return &Server{
+		Logger:      log,
+		srv:         &http.Server{Addr: addr, Handler: h},
+		GracePeriod: 20 * time.Second,
+	}
+}
+
+// Run listens on the address passed to New and serves requests until ctx is done, then shuts down gracefully
+func (s *Server) Run(ctx context.Context) error {
+	l, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, l)
+}
+
+// Serve requests on l until ctx is done, then shuts down gracefully.
+// Serve only returns after all Closers have been closed.
+func (s *Server) Serve(ctx context.Context, l net.Listener) (err error) {
+	defer func() {
+		if cerr := s.close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	errs := make(chan error, 1)
+	go func() {
+		s.Info("listening", zap.String("addr", l.Addr().String()))
+		errs <- s.srv.Serve(l)
+	}()
+
+	select {
+	case err := <-errs:
+		s.Error("server error", zap.Error(err))
+		return err
+	case <-ctx.Done():
+	}
+	return s.shutdown()
+}
+
+func (s *Server) shutdown() error {
+	s.Info("shutting down", zap.Duration("drainDelay", s.DrainDelay), zap.Duration("gracePeriod", s.GracePeriod))
+	if s.Health != nil {
+		s.Health.Drain()
+	}
+	time.Sleep(s.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.GracePeriod)
+	defer cancel()
+	if err := s.srv.Shutdown(ctx); err != nil {
+		s.Error("graceful shutdown failed, closing remaining connections", zap.Error(err))
+		s.srv.Close()
+		return err
+	}
+	s.Info("all requests served")
+	return nil
+}
+
+func (s *Server) close() (err error) {
+	for _, c := range s.Closers {
+		if cerr := c.Close(); cerr != nil {
+			s.Error("close error", zap.Error(cerr))
+			if err == nil {
+				err = cerr
+			}
+		}
+	}
+	return err
+}
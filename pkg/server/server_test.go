@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kwiesmueller/ubisoft-backend-interview/pkg/health"
+	"github.com/playnet-public/libs/log"
+)
+
+// closer records whether it was closed and how many requests were still running at that time
+type closer struct {
+	running *int32
+	closed  chan int32
+}
+
+func (c *closer) Close() error {
+	c.closed <- atomic.LoadInt32(c.running)
+	return nil
+}
+
+func listen(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestServer_ShutdownDrainsRequests(t *testing.T) {
+	const requests = 10
+
+	var running int32
+	started := make(chan struct{}, requests)
+	release := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		started <- struct{}{}
+		<-release
+		w.Write([]byte("ok"))
+	})
+
+	l := listen(t)
+	c := &closer{running: &running, closed: make(chan int32, 1)}
+	srv := New(log.NewNop(), "", h)
+	srv.Health = health.New(log.NewNop())
+	srv.Closers = append(srv.Closers, c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, l) }()
+
+	var wg sync.WaitGroup
+	var served int32
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Post("http://"+l.Addr().String()+"/", "application/json", nil)
+			if err != nil {
+				t.Error("request lost:", err)
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+			if resp.StatusCode == http.StatusOK && string(body) == "ok" {
+				atomic.AddInt32(&served, 1)
+			}
+		}()
+	}
+	for i := 0; i < requests; i++ {
+		<-started
+	}
+
+	// shut down while all requests are in flight
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	if !srv.Health.Draining() {
+		t.Error("server still reports ready while shutting down")
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("Serve() returned before in-flight requests finished: %v", err)
+	case <-c.closed:
+		t.Fatal("closed resources before in-flight requests finished")
+	default:
+	}
+
+	close(release)
+	wg.Wait()
+	if err := <-done; err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+	if served != requests {
+		t.Fatalf("served %d of %d requests", served, requests)
+	}
+	if n := <-c.closed; n != 0 {
+		t.Fatalf("closed resources with %d requests still running", n)
+	}
+
+	if _, err := http.Get("http://" + l.Addr().String() + "/"); err == nil {
+		t.Fatal("server still accepts requests after shutdown")
+	}
+}
+
+func TestServer_ShutdownGracePeriod(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	var running int32
+	l := listen(t)
+	c := &closer{running: &running, closed: make(chan int32, 1)}
+	srv := New(log.NewNop(), "", h)
+	srv.GracePeriod = 10 * time.Millisecond
+	srv.Closers = append(srv.Closers, c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, l) }()
+
+	go http.Get("http://" + l.Addr().String() + "/")
+	<-started
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("Serve() error = %v want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve() did not give up after the grace period")
+	}
+	select {
+	case <-c.closed:
+	default:
+		t.Fatal("resources not closed after the grace period")
+	}
+}